@@ -0,0 +1,14 @@
+package handler
+
+import "github.com/gofiber/fiber/v2"
+
+func (h *RestHandler) Availability(ctx *fiber.Ctx) error {
+	availability, err := h.service.Availability(ctx.Query("username"), ctx.Query("email"))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":       "success",
+		"availability": availability,
+	})
+}
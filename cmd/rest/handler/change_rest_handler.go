@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	writeTimeout      = 10 * time.Second
+)
+
+// the feed is authorized by token rather than cookies, so pages on any
+// origin may open it
+var upgrader = websocket.FastHTTPUpgrader{
+	CheckOrigin: func(*fasthttp.RequestCtx) bool { return true },
+}
+
+// StreamToken takes the bearer token of the change feed from the
+// access_token query parameter when the Authorization header is missing,
+// as browsers cannot set headers on EventSource and WebSocket requests. It
+// must run before TokenValidate.
+func (h *RestHandler) StreamToken(ctx *fiber.Ctx) error {
+	if token := ctx.Query("access_token"); token != "" && ctx.Get(fiber.HeaderAuthorization) == "" {
+		ctx.Request().Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	return ctx.Next()
+}
+
+// Changes streams the changes of the users listed in the uuids query
+// parameter, by default the caller, as server-sent events or, on an
+// upgrade request, as WebSocket messages. Any signed-in user may follow
+// anyone; the events carry no personal data. A client resumes with the
+// Last-Event-ID header or the last_event_id query parameter.
+func (h *RestHandler) Changes(ctx *fiber.Ctx) error {
+	claims, ok := ctx.Locals("claims").(entity.ClaimsEntity)
+	if !ok {
+		return problem(ctx, errs.Unauthorized("Not Authorized"))
+	}
+	uuids := []string{claims.Subject}
+	if ctx.Query("uuids") != "" {
+		uuids = strings.Split(ctx.Query("uuids"), ",")
+	}
+	lastEventID := ctx.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("last_event_id")
+	}
+	stream, cancel := context.WithCancel(context.Background())
+	changes, err := h.changes.Subscribe(stream, uuids, lastEventID)
+	if err != nil {
+		cancel()
+		return problem(ctx, err)
+	}
+	if websocket.FastHTTPIsWebSocketUpgrade(ctx.Context()) {
+		err := upgrader.Upgrade(ctx.Context(), func(conn *websocket.Conn) {
+			defer cancel()
+			socket(conn, cancel, changes)
+		})
+		if err != nil {
+			cancel()
+		}
+		return nil
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+		w.WriteString("retry: 3000\n\n")
+		for {
+			if err := w.Flush(); err != nil {
+				return
+			}
+			select {
+			case change, ok := <-changes:
+				if !ok {
+					return
+				}
+				if change.Reset {
+					w.WriteString("event: reset\ndata: {}\n\n")
+					continue
+				}
+				w.WriteString("id: " + change.ID + "\nevent: change\ndata: ")
+				w.Write(change.Event)
+				w.WriteString("\n\n")
+			case <-keepAlive.C:
+				w.WriteString(": keep-alive\n\n")
+			}
+		}
+	})
+	return nil
+}
+
+// socket writes changes to conn as JSON messages shaped like the events
+// of the SSE stream: {"id", "event", "data"}.
+func socket(conn *websocket.Conn, cancel context.CancelFunc, changes <-chan entity.ChangeEntity) {
+	defer conn.Close()
+	go func() {
+		// the client sends nothing but control frames; reading handles
+		// them and notices when it goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, ""), time.Now().Add(writeTimeout))
+				return
+			}
+			message := map[string]interface{}{"event": "reset"}
+			if !change.Reset {
+				message = map[string]interface{}{"id": change.ID, "event": "change", "data": change.Event}
+			}
+			b, err := sonic.Marshal(message)
+			if err != nil {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
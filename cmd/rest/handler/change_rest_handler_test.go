@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// refusingFeed records what it is asked to follow and refuses it, so the
+// handler answers without streaming.
+type refusingFeed struct {
+	port.ChangeFeed
+	uuids []string
+}
+
+func (f *refusingFeed) Subscribe(ctx context.Context, uuids []string, lastEventID string) (<-chan entity.ChangeEntity, error) {
+	f.uuids = uuids
+	return nil, errs.Unavailable("refused", nil)
+}
+
+func TestChangesFollowsAnyUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims entity.ClaimsEntity
+		query  string
+		status int
+		uuids  string
+	}{
+		{"self by default", entity.ClaimsEntity{Subject: "u1"}, "", fiber.StatusServiceUnavailable, "u1"},
+		{"self", entity.ClaimsEntity{Subject: "u1"}, "?uuids=u1", fiber.StatusServiceUnavailable, "u1"},
+		{"other users", entity.ClaimsEntity{Subject: "u1"}, "?uuids=u2,u3", fiber.StatusServiceUnavailable, "u2,u3"},
+		{"admin", entity.ClaimsEntity{Subject: "a1", Scopes: []string{"admin"}}, "?uuids=u1,u2", fiber.StatusServiceUnavailable, "u1,u2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := &refusingFeed{}
+			h := NewRestHandler(nil, nil, nil, nil, nil, nil, nil, feed, nil)
+			app := fiber.New()
+			app.Get("/users/changes", func(ctx *fiber.Ctx) error {
+				ctx.Locals("claims", tt.claims)
+				return ctx.Next()
+			}, h.Changes)
+			res, err := app.Test(httptest.NewRequest("GET", "/users/changes"+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.status {
+				t.Errorf("status %d, want %d", res.StatusCode, tt.status)
+			}
+			if got := strings.Join(feed.uuids, ","); got != tt.uuids {
+				t.Errorf("followed %q, want %q", got, tt.uuids)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *RestHandler) DeadJobs(ctx *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	jobs, err := h.jobs.DeadLetters(limit)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status": "success",
+		"jobs":   jobs,
+	})
+}
+
+func (h *RestHandler) RedriveJob(ctx *fiber.Ctx) error {
+	if err := h.jobs.Redrive(ctx.Params("id")); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(202).JSON(map[string]string{
+		"status": "success",
+	})
+}
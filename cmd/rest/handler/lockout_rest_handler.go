@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+func (h *RestHandler) UnlockUser(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	return unlocked(ctx, h.lockout.UnlockUser(ctx.Params("uuid"), claims.Subject))
+}
+
+func (h *RestHandler) UnlockIP(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	return unlocked(ctx, h.lockout.UnlockIP(ctx.Params("ip"), claims.Subject))
+}
+
+func unlocked(ctx *fiber.Ctx, err error) error {
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+func (h *RestHandler) SetupTotp(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	setup, err := h.mfa.SetupTotp(claims.Subject)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(201).JSON(map[string]interface{}{
+		"status": "success",
+		"totp":   setup,
+	})
+}
+
+func (h *RestHandler) ConfirmTotp(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	body := &entity.MfaCodeEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	codes, err := h.mfa.ConfirmTotp(claims.Subject, body.Code)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":         "success",
+		"recovery_codes": codes,
+	})
+}
+
+func (h *RestHandler) DisableTotp(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	body := &entity.MfaCodeEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	if err := h.mfa.DisableTotp(claims.Subject, body.Code); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
+
+func (h *RestHandler) LoginMfa(ctx *fiber.Ctx) error {
+	body := &entity.MfaLoginEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	token, err := h.mfa.Login(body.MfaToken, body.Code, client(ctx))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(tokenResponse(token))
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+func (h *RestHandler) BeginPasskeyRegistration(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	ceremony, err := h.passkeys.BeginRegistration(claims.Subject)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(ceremony)
+}
+
+func (h *RestHandler) FinishPasskeyRegistration(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	result := &entity.CeremonyResultEntity{}
+	if err := parseBody(ctx, result); err != nil {
+		return problem(ctx, err)
+	}
+	passkey, err := h.passkeys.FinishRegistration(claims.Subject, *result)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(201).JSON(map[string]interface{}{
+		"status":  "success",
+		"passkey": passkey,
+	})
+}
+
+func (h *RestHandler) BeginPasskeyLogin(ctx *fiber.Ctx) error {
+	ceremony, err := h.passkeys.BeginLogin()
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(ceremony)
+}
+
+func (h *RestHandler) FinishPasskeyLogin(ctx *fiber.Ctx) error {
+	result := &entity.CeremonyResultEntity{}
+	if err := parseBody(ctx, result); err != nil {
+		return problem(ctx, err)
+	}
+	token, err := h.passkeys.FinishLogin(*result, client(ctx))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(tokenResponse(token))
+}
+
+func (h *RestHandler) Passkeys(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	passkeys, err := h.passkeys.List(claims.Subject)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":   "success",
+		"passkeys": passkeys,
+	})
+}
+
+func (h *RestHandler) DeletePasskey(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	if err := h.passkeys.Revoke(claims.Subject, ctx.Params("id")); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
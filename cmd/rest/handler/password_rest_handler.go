@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+// ForgotPassword answers the same way for every address, registered or not.
+func (h *RestHandler) ForgotPassword(ctx *fiber.Ctx) error {
+	body := &entity.ForgotPasswordEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	h.service.ForgotPassword(body.Email)
+	return ctx.Status(202).JSON(map[string]string{
+		"status":  "success",
+		"message": "if the address belongs to an account, a reset link is on its way",
+	})
+}
+
+func (h *RestHandler) ResetPassword(ctx *fiber.Ctx) error {
+	body := &entity.ResetPasswordEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	if err := h.service.ResetPassword(ctx.UserContext(), body.Token, body.Password); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
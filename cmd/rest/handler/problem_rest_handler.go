@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/errmap"
+)
+
+// problem answers with the status and RFC 7807 body err maps to.
+func problem(ctx *fiber.Ctx, err error) error {
+	p := errmap.HTTP(err, ctx.Path())
+	if retry := errmap.RetryAfter(err); retry > 0 {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(retry))
+	}
+	return writeProblem(ctx, p)
+}
+
+func writeProblem(ctx *fiber.Ctx, p errmap.Problem) error {
+	if err := ctx.Status(p.Status).JSON(p); err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderContentType, errmap.ContentType)
+	return nil
+}
+
+// parseBody decodes the request body into out, rejecting one that does not
+// parse instead of going on with a zero value.
+func parseBody(ctx *fiber.Ctx, out interface{}) error {
+	if err := ctx.BodyParser(out); err != nil {
+		return errs.Validation("malformed request body", errs.FieldError{Field: "body", Rule: "parse", Message: err.Error()})
+	}
+	return nil
+}
+
+// ErrorHandler answers errors that escape the handlers, such as unknown
+// routes or panics, as problems too.
+func ErrorHandler(ctx *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return writeProblem(ctx, errmap.Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(fe.Code),
+			Status:   fe.Code,
+			Detail:   fe.Message,
+			Instance: ctx.Path(),
+		})
+	}
+	return problem(ctx, err)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+func (h *RestHandler) Refresh(ctx *fiber.Ctx) error {
+	body := &entity.RefreshEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	token, err := h.sessions.Refresh(body.RefreshToken, client(ctx))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(tokenResponse(token))
+}
+
+func (h *RestHandler) Logout(ctx *fiber.Ctx) error {
+	body := &entity.RefreshEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	if err := h.sessions.Logout(body.RefreshToken); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
+
+func (h *RestHandler) Sessions(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	sessions, err := h.sessions.List(claims.Subject)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":   "success",
+		"sessions": sessions,
+	})
+}
+
+func (h *RestHandler) DeleteSession(ctx *fiber.Ctx) error {
+	claims := ctx.Locals("claims").(entity.ClaimsEntity)
+	if err := h.sessions.Revoke(claims.Subject, ctx.Params("id")); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
+
+func client(ctx *fiber.Ctx) entity.ClientEntity {
+	return entity.ClientEntity{
+		IP:        ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+	}
+}
+
+func tokenResponse(token entity.TokenEntity) map[string]interface{} {
+	return map[string]interface{}{
+		"status":        "success",
+		"access_token":  token.AccessToken,
+		"token_type":    token.TokenType,
+		"expires_in":    token.ExpiresIn,
+		"refresh_token": token.RefreshToken,
+	}
+}
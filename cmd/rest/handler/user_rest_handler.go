@@ -1,31 +1,61 @@
 package handler
 
 import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
 	"github.com/koalachatapp/user/internal/core/port"
 )
 
+const headerCorrelationID = "X-Correlation-ID"
+
 type RestHandler struct {
-	service port.UserService
+	service    port.UserService
+	sessions   port.SessionService
+	jobs       port.JobService
+	mfa        port.MfaService
+	passkeys   port.PasskeyService
+	lockout    port.LockoutService
+	webhooks   port.WebhookService
+	changes    port.ChangeFeed
+	verifier   port.TokenVerifier
+	adminScope string
 }
 
-func NewRestHandler(service port.UserService) *RestHandler {
+func NewRestHandler(service port.UserService, sessions port.SessionService, jobs port.JobService, mfa port.MfaService, passkeys port.PasskeyService, lockout port.LockoutService, webhooks port.WebhookService, changes port.ChangeFeed, verifier port.TokenVerifier) *RestHandler {
+	adminScope := os.Getenv("ADMIN_SCOPE")
+	if adminScope == "" {
+		adminScope = "admin"
+	}
 	return &RestHandler{
-		service: service,
+		service:    service,
+		sessions:   sessions,
+		jobs:       jobs,
+		mfa:        mfa,
+		passkeys:   passkeys,
+		lockout:    lockout,
+		webhooks:   webhooks,
+		changes:    changes,
+		verifier:   verifier,
+		adminScope: adminScope,
 	}
 }
 
 func (h *RestHandler) Post(ctx *fiber.Ctx) error {
 	user := &entity.UserEntity{}
-	ctx.BodyParser(user)
+	if err := parseBody(ctx, user); err != nil {
+		return problem(ctx, err)
+	}
 
-	uuid, err := h.service.Register(*user)
+	uuid, err := h.service.Register(ctx.UserContext(), *user)
 	if err != nil {
-		if err.Error() == "user already registered" {
-			return ctx.Status(409).JSON(map[string]string{"status": "error", "message": err.Error()})
-		}
-		return ctx.Status(400).JSON(map[string]string{"status": "error", "message": err.Error()})
+		return problem(ctx, err)
 	}
 	return ctx.Status(201).JSON(map[string]string{
 		"status": "success",
@@ -35,13 +65,10 @@ func (h *RestHandler) Post(ctx *fiber.Ctx) error {
 
 func (h *RestHandler) Delete(ctx *fiber.Ctx) error {
 	uuid := ctx.Params("uuid")
-	if err := h.service.Delete(uuid); err != nil {
-		if err.Error() == "uuid not found" {
-			return ctx.Status(409).JSON(map[string]string{"status": "error", "message": err.Error()})
-		}
-		return ctx.Status(400).JSON(map[string]string{"status": "error", "message": err.Error()})
+	if err := h.service.Delete(ctx.UserContext(), uuid); err != nil {
+		return problem(ctx, err)
 	}
-	return ctx.Status(201).JSON(map[string]string{
+	return ctx.Status(200).JSON(map[string]string{
 		"status": "success",
 	})
 }
@@ -49,14 +76,14 @@ func (h *RestHandler) Delete(ctx *fiber.Ctx) error {
 func (h *RestHandler) Put(ctx *fiber.Ctx) error {
 	uuid := ctx.Params("uuid")
 	user := &entity.UserEntity{}
-	ctx.BodyParser(user)
-	if err := h.service.Update(uuid, *user); err != nil {
-		if err.Error() == "uuid not found" {
-			return ctx.Status(409).JSON(map[string]string{"status": "error", "message": err.Error()})
-		}
-		return ctx.Status(400).JSON(map[string]string{"status": "error", "message": err.Error()})
+	if err := parseBody(ctx, user); err != nil {
+		return problem(ctx, err)
 	}
-	return ctx.Status(201).JSON(map[string]string{
+	if err := h.service.Update(ctx.UserContext(), uuid, *user); err != nil {
+		return problem(ctx, err)
+	}
+	// the change is queued, not yet stored
+	return ctx.Status(202).JSON(map[string]string{
 		"status": "success",
 	})
 }
@@ -64,27 +91,161 @@ func (h *RestHandler) Put(ctx *fiber.Ctx) error {
 func (h *RestHandler) Patch(ctx *fiber.Ctx) error {
 	uuid := ctx.Params("uuid")
 	user := &entity.UserEntity{}
-	ctx.BodyParser(user)
-	if err := h.service.Patch(uuid, *user); err != nil {
-		if err.Error() == "uuid not found" {
-			return ctx.Status(409).JSON(map[string]string{"status": "error", "message": err.Error()})
-		}
-		return ctx.Status(400).JSON(map[string]string{"status": "error", "message": err.Error()})
+	if err := parseBody(ctx, user); err != nil {
+		return problem(ctx, err)
 	}
-	return ctx.Status(201).JSON(map[string]string{
+	if err := h.service.Patch(ctx.UserContext(), uuid, *user); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(202).JSON(map[string]string{
 		"status": "success",
 	})
 }
 
+func (h *RestHandler) Get(ctx *fiber.Ctx) error {
+	user, err := h.service.Get(ctx.Params("uuid"))
+	return h.profile(ctx, user, err)
+}
+
+func (h *RestHandler) GetByUsername(ctx *fiber.Ctx) error {
+	user, err := h.service.GetByUsername(ctx.Params("username"))
+	return h.profile(ctx, user, err)
+}
+
+func (h *RestHandler) GetByEmail(ctx *fiber.Ctx) error {
+	user, err := h.service.GetByEmail(ctx.Params("email"))
+	return h.profile(ctx, user, err)
+}
+
+// profile answers with user, leaving out the email and its verified state
+// unless the caller is the user or an admin.
+func (h *RestHandler) profile(ctx *fiber.Ctx, user entity.UserProfileEntity, err error) error {
+	if err != nil {
+		return problem(ctx, err)
+	}
+	var body interface{} = user.Public()
+	if h.owns(ctx, user.Uuid) {
+		body = user
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status": "success",
+		"user":   body,
+	})
+}
+
+func (h *RestHandler) List(ctx *fiber.Ctx) error {
+	query := entity.ListQuery{
+		Cursor: ctx.Query("cursor"),
+		Prefix: ctx.Query("q"),
+	}
+	var err error
+	if limit := ctx.Query("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return problem(ctx, errs.Invalid("limit", "integer", "invalid limit"))
+		}
+	}
+	if after := ctx.Query("created_after"); after != "" {
+		if query.CreatedAfter, err = time.Parse(time.RFC3339, after); err != nil {
+			return problem(ctx, errs.Invalid("created_after", "rfc3339", "invalid created_after"))
+		}
+	}
+	if before := ctx.Query("created_before"); before != "" {
+		if query.CreatedBefore, err = time.Parse(time.RFC3339, before); err != nil {
+			return problem(ctx, errs.Invalid("created_before", "rfc3339", "invalid created_before"))
+		}
+	}
+	page, err := h.service.List(query)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":      "success",
+		"users":       page.Users,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+	})
+}
+
+func (h *RestHandler) Login(ctx *fiber.Ctx) error {
+	login := &entity.LoginEntity{}
+	if err := parseBody(ctx, login); err != nil {
+		return problem(ctx, err)
+	}
+	token, err := h.service.Authenticate(ctx.UserContext(), login.Login, login.Password, client(ctx))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	if token.MfaToken != "" {
+		return ctx.Status(200).JSON(map[string]string{
+			"status":    "mfa_required",
+			"mfa_token": token.MfaToken,
+		})
+	}
+	return ctx.Status(200).JSON(tokenResponse(token))
+}
+
+// Trace stores the correlation id of the request in its user context,
+// taking it from X-Correlation-ID or making one up, and echoes it back.
+func (h *RestHandler) Trace(ctx *fiber.Ctx) error {
+	id := ctx.Get(headerCorrelationID)
+	if id == "" || len(id) > 128 {
+		id = uuid.New().String()
+	}
+	ctx.Set(headerCorrelationID, id)
+	ctx.SetUserContext(entity.WithTrace(ctx.UserContext(), entity.TraceEntity{CorrelationID: id}))
+	return ctx.Next()
+}
+
+// Admin lets the request through only when the caller holds the admin scope.
+// It must run after TokenValidate.
+func (h *RestHandler) Admin(ctx *fiber.Ctx) error {
+	claims, ok := ctx.Locals("claims").(entity.ClaimsEntity)
+	if !ok {
+		return problem(ctx, errs.Unauthorized("Not Authorized"))
+	}
+	if !claims.HasScope(h.adminScope) {
+		return problem(ctx, errs.Forbidden("Forbidden"))
+	}
+	return ctx.Next()
+}
+
+// TokenValidate verifies the bearer token of the request and stores its
+// claims in the context under "claims".
 func (h *RestHandler) TokenValidate(ctx *fiber.Ctx) error {
-	head := ctx.GetReqHeaders()
-	if head["Token"] == "" {
-		return ctx.Status(401).JSON(map[string]string{"status": "error", "message": "Not Authorized"})
+	auth := ctx.Get(fiber.HeaderAuthorization)
+	if auth == "" {
+		return problem(ctx, errs.Unauthorized("Not Authorized"))
 	}
-	// FUTURE: call from auth server for validate token
-	if head["Token"] != "koala" {
-		return ctx.Status(401).JSON(map[string]string{"status": "error", "message": "Invalid Authorization"})
+	token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	if !strings.HasPrefix(auth, "Bearer ") || token == "" {
+		return problem(ctx, errs.Unauthorized("Invalid Authorization"))
 	}
+	claims, err := h.verifier.Verify(ctx.UserContext(), token)
+	if err != nil {
+		return problem(ctx, errs.Unauthorized("Invalid Authorization"))
+	}
+	ctx.Locals("claims", claims)
+	trace := entity.TraceFrom(ctx.UserContext())
+	trace.Actor = claims.Subject
+	ctx.SetUserContext(entity.WithTrace(ctx.UserContext(), trace))
+	return ctx.Next()
+}
 
+// Owner lets the request through only when the caller is the user named by
+// the :uuid parameter or holds the admin scope. It must run after
+// TokenValidate.
+func (h *RestHandler) Owner(ctx *fiber.Ctx) error {
+	if _, ok := ctx.Locals("claims").(entity.ClaimsEntity); !ok {
+		return problem(ctx, errs.Unauthorized("Not Authorized"))
+	}
+	if !h.owns(ctx, ctx.Params("uuid")) {
+		return problem(ctx, errs.Forbidden("Forbidden"))
+	}
 	return ctx.Next()
 }
+
+// owns tells whether the caller is the user uuid or holds the admin scope.
+func (h *RestHandler) owns(ctx *fiber.Ctx, uuid string) bool {
+	claims, ok := ctx.Locals("claims").(entity.ClaimsEntity)
+	return ok && (claims.Subject == uuid || claims.HasScope(h.adminScope))
+}
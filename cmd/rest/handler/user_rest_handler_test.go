@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// profileService knows a single user.
+type profileService struct {
+	port.UserService
+	user entity.UserProfileEntity
+}
+
+func (s profileService) Get(uuid string) (entity.UserProfileEntity, error) {
+	return s.user, nil
+}
+
+func TestProfileHidesEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims entity.ClaimsEntity
+		email  bool
+	}{
+		{"owner", entity.ClaimsEntity{Subject: "u1"}, true},
+		{"other user", entity.ClaimsEntity{Subject: "u2"}, false},
+		{"admin", entity.ClaimsEntity{Subject: "a1", Scopes: []string{"admin"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := profileService{user: entity.UserProfileEntity{Uuid: "u1", Username: "koala", Name: "Kö Ala", Email: "koala@example.com", Verified: true}}
+			h := NewRestHandler(service, nil, nil, nil, nil, nil, nil, nil, nil)
+			app := fiber.New()
+			app.Get("/users/:uuid", func(ctx *fiber.Ctx) error {
+				ctx.Locals("claims", tt.claims)
+				return ctx.Next()
+			}, h.Get)
+			res, err := app.Test(httptest.NewRequest("GET", "/users/u1", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body struct {
+				User map[string]interface{} `json:"user"`
+			}
+			if err := sonic.Unmarshal(b, &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.User["username"] != "koala" {
+				t.Errorf("user %v lacks the username", body.User)
+			}
+			_, email := body.User["email"]
+			_, verified := body.User["verified"]
+			if email != tt.email || verified != tt.email || strings.Contains(string(b), "koala@example.com") != tt.email {
+				t.Errorf("user %v, want email shown %v", body.User, tt.email)
+			}
+		})
+	}
+}
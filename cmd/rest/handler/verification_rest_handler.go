@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+func (h *RestHandler) VerifyEmail(ctx *fiber.Ctx) error {
+	body := &entity.VerifyEmailEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	if err := h.service.VerifyEmail(ctx.UserContext(), body.Token); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
+
+// ResendVerification answers the same way for every address, registered or
+// not.
+func (h *RestHandler) ResendVerification(ctx *fiber.Ctx) error {
+	body := &entity.ResendVerificationEntity{}
+	if err := parseBody(ctx, body); err != nil {
+		return problem(ctx, err)
+	}
+	h.service.ResendVerification(body.Email)
+	return ctx.Status(202).JSON(map[string]string{
+		"status":  "success",
+		"message": "if the address belongs to an unverified account, a verification link is on its way",
+	})
+}
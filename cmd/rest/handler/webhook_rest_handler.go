@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+func (h *RestHandler) CreateWebhook(ctx *fiber.Ctx) error {
+	request := &entity.WebhookRequestEntity{}
+	if err := parseBody(ctx, request); err != nil {
+		return problem(ctx, err)
+	}
+	webhook, err := h.webhooks.Create(*request)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(201).JSON(map[string]interface{}{
+		"status":  "success",
+		"webhook": webhook,
+	})
+}
+
+func (h *RestHandler) Webhooks(ctx *fiber.Ctx) error {
+	webhooks, err := h.webhooks.List()
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":   "success",
+		"webhooks": webhooks,
+	})
+}
+
+func (h *RestHandler) GetWebhook(ctx *fiber.Ctx) error {
+	webhook, err := h.webhooks.Get(ctx.Params("id"))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":  "success",
+		"webhook": webhook,
+	})
+}
+
+func (h *RestHandler) UpdateWebhook(ctx *fiber.Ctx) error {
+	request := &entity.WebhookRequestEntity{}
+	if err := parseBody(ctx, request); err != nil {
+		return problem(ctx, err)
+	}
+	webhook, err := h.webhooks.Update(ctx.Params("id"), *request)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":  "success",
+		"webhook": webhook,
+	})
+}
+
+func (h *RestHandler) DeleteWebhook(ctx *fiber.Ctx) error {
+	if err := h.webhooks.Delete(ctx.Params("id")); err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]string{
+		"status": "success",
+	})
+}
+
+func (h *RestHandler) WebhookDeliveries(ctx *fiber.Ctx) error {
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	deliveries, err := h.webhooks.Deliveries(ctx.Params("id"), ctx.Query("status"), limit)
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":     "success",
+		"deliveries": deliveries,
+	})
+}
+
+func (h *RestHandler) WebhookDelivery(ctx *fiber.Ctx) error {
+	delivery, err := h.webhooks.Delivery(ctx.Params("id"), ctx.Params("delivery"))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(200).JSON(map[string]interface{}{
+		"status":   "success",
+		"delivery": delivery,
+	})
+}
+
+func (h *RestHandler) ReplayWebhookDelivery(ctx *fiber.Ctx) error {
+	delivery, err := h.webhooks.Replay(ctx.Params("id"), ctx.Params("delivery"))
+	if err != nil {
+		return problem(ctx, err)
+	}
+	return ctx.Status(202).JSON(map[string]interface{}{
+		"status":   "success",
+		"delivery": delivery,
+	})
+}
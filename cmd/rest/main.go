@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"math/rand"
 	"os"
+	"os/signal"
 	"runtime"
+	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/Shopify/sarama"
 	"github.com/bytedance/sonic"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -17,9 +20,18 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/monitor"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/koalachatapp/user/cmd/rest/handler"
-	"github.com/koalachatapp/user/internal/core/port"
+	"github.com/koalachatapp/user/internal/core/errs"
 	"github.com/koalachatapp/user/internal/core/service"
+	"github.com/koalachatapp/user/internal/encryption"
+	"github.com/koalachatapp/user/internal/hasher"
+	"github.com/koalachatapp/user/internal/mailer"
+	"github.com/koalachatapp/user/internal/policy"
+	"github.com/koalachatapp/user/internal/publisher"
 	"github.com/koalachatapp/user/internal/repository"
+	"github.com/koalachatapp/user/internal/token"
+	"github.com/koalachatapp/user/internal/validation"
+	"github.com/koalachatapp/user/internal/webhook"
+	"github.com/koalachatapp/user/internal/worker"
 )
 
 func main() {
@@ -28,81 +40,209 @@ func main() {
 	// repository
 	userrepo := repository.NewUserRepository()
 
-	saramaconfig := sarama.NewConfig()
-	saramaconfig.Producer.Return.Successes = true
-	saramaconfig.Net.MaxOpenRequests = 5
-	saramaddr := os.Getenv("KAFKA_URL")
-	if saramaddr == "" {
-		saramaddr = "kafka:9092"
+	bus := publisher.NewEventPublisher()
+
+	// worker
+	pool := worker.NewPool()
+	pool.Start(context.Background())
+
+	mfabox, err := encryption.NewSecretBox("MFA_ENCRYPTION_KEY")
+	if err != nil {
+		log.Fatal(err)
 	}
-	prod, err := sarama.NewSyncProducer([]string{saramaddr}, saramaconfig)
+	webhookbox, err := encryption.NewSecretBox("WEBHOOK_ENCRYPTION_KEY")
 	if err != nil {
-		log.Println(err)
-	} else {
-		defer prod.Close()
+		log.Fatal(err)
 	}
-
-	// worker
-	worker := port.Worker{
-		Wg:         sync.WaitGroup{},
-		Wg2:        sync.WaitGroup{},
-		Worker:     make(chan map[uint8]interface{}),
-		Prod:       prod,
-		Lock:       sync.Mutex{},
-		Send2kafka: make(chan string, 256),
+	jobbox, err := encryption.NewSecretBox("JOB_ENCRYPTION_KEY")
+	if err != nil {
+		log.Fatal(err)
 	}
-	defer worker.Wg.Wait()
-	defer worker.Wg2.Wait()
-	// go worker.RunProduserWorker()
 
 	// service
-	userservice := service.NewUserService(userrepo, &worker)
+	sessionservice := service.NewSessionService(repository.NewSessionRepository(), token.NewJwtIssuer())
+	jobservice := service.NewJobService(repository.NewJobRepository(), pool, jobbox)
+	lockoutservice := service.NewLockoutService(repository.NewLockoutRepository(), repository.NewOutboxRepository(), pool)
+	mfaservice := service.NewMfaService(repository.NewMfaRepository(), userrepo, repository.NewMfaChallengeRepository(), mfabox, sessionservice, lockoutservice)
+	userservice := service.NewUserService(userrepo, pool, hasher.NewPasswordHasher(), policy.NewPasswordPolicy(), validation.NewValidator(), sessionservice, mfaservice, lockoutservice, repository.NewVerificationRepository(), repository.NewPasswordResetRepository(), mailer.NewMailer(), repository.NewAvailabilityRepository(), repository.NewRedisClient())
+	passkeyservice, err := service.NewPasskeyService(repository.NewPasskeyRepository(), userrepo, repository.NewCeremonyRepository(), sessionservice)
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhookservice := service.NewWebhookService(repository.NewWebhookRepository(), webhook.NewHttpSender(), webhookbox, validation.NewValidator())
+	changefeed := service.NewChangeFeed(repository.NewChangeLogRepository())
+	relay := service.NewOutboxRelay(repository.NewOutboxRepository(), publisher.NewFanoutPublisher(bus, webhookservice, changefeed), pool)
+	background, stopBackground := context.WithCancel(context.Background())
+	var loops sync.WaitGroup
+	loops.Add(4)
+	go func() {
+		defer loops.Done()
+		jobservice.Run(background)
+	}()
+	go func() {
+		defer loops.Done()
+		relay.Run(background)
+	}()
+	go func() {
+		defer loops.Done()
+		webhookservice.Run(background)
+	}()
+	go func() {
+		defer loops.Done()
+		changefeed.Run(background)
+	}()
 
 	// handler
-	userhandler := handler.NewRestHandler(userservice)
+	userhandler := handler.NewRestHandler(userservice, sessionservice, jobservice, mfaservice, passkeyservice, lockoutservice, webhookservice, changefeed, token.NewTokenVerifier())
 
+	// Prefork children are killed by the parent without a chance to drain
+	// their worker pool, so it is opt-in; their unfinished jobs are only
+	// recovered once the retry lease runs out.
 	app := fiber.New(fiber.Config{
-		Prefork:           true,
+		Prefork:           os.Getenv("PREFORK") == "true",
 		CaseSensitive:     true,
 		UnescapePath:      true,
 		ReduceMemoryUsage: true,
 		JSONEncoder:       sonic.Marshal,
 		JSONDecoder:       sonic.Unmarshal,
+		ErrorHandler:      handler.ErrorHandler,
 	})
 	app.Use(recover.New())
+	app.Use(userhandler.Trace)
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 	app.Use(logger.New(logger.Config{
 		TimeZone:     "Asian/Jakarta",
 		TimeInterval: time.Millisecond,
 	}))
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "127.0.0.1," + os.Getenv("ALLOWED_HOSTS"),
-		AllowHeaders: "Origin,Content-Type,Accept",
-		AllowMethods: "GET,POST,PUT,PATCH,DELETE",
+		AllowOrigins:  "127.0.0.1," + os.Getenv("ALLOWED_HOSTS"),
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-Correlation-ID",
+		ExposeHeaders: "X-Correlation-ID",
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE",
 	}))
 	app.Use(limiter.New(limiter.Config{
 		Expiration: 5 * time.Second,
 		Max:        100,
 		LimitReached: func(c *fiber.Ctx) error {
-			return c.JSON(map[string]string{"status": "error", "message": "too fast"})
+			return handler.ErrorHandler(c, errs.TooManyRequests("too fast", 5*time.Second))
 		},
 	}))
-	app.Use("/register", userhandler.TokenValidate)
+	// availability is called on every key stroke of the signup form and
+	// tells whether an email is registered, so it gets a tighter budget,
+	// shared with the lookup by email which tells the same
+	availabilityLimit, err := strconv.Atoi(os.Getenv("AVAILABILITY_RATE_LIMIT"))
+	if err != nil || availabilityLimit <= 0 {
+		availabilityLimit = 20
+	}
+	availabilityLimiter := limiter.New(limiter.Config{
+		Expiration: time.Minute,
+		Max:        availabilityLimit,
+		LimitReached: func(c *fiber.Ctx) error {
+			return handler.ErrorHandler(c, errs.TooManyRequests("too many availability checks", time.Minute))
+		},
+	})
+	// /register stays open to anonymous signups, guarded by the limiter
 	app.Use("/remove", userhandler.TokenValidate)
 	app.Use("/update", userhandler.TokenValidate)
 	app.Use("/patch", userhandler.TokenValidate)
+	app.Use("/sessions", userhandler.TokenValidate)
+	app.Use("/users/changes", userhandler.StreamToken)
+	app.Use("/users", userhandler.TokenValidate)
+	app.Use("/mfa", userhandler.TokenValidate)
+	app.Use("/passkeys/register", userhandler.TokenValidate)
+	app.Use("/admin", userhandler.TokenValidate, userhandler.Admin)
 	if os.Getenv("ENV") == "dev" {
 		app.Get("/monitor", monitor.New(monitor.Config{Refresh: 1 * time.Second}))
 	}
 	app.Post("/register", userhandler.Post)
-	app.Delete("/remove/:uuid", userhandler.Delete)
-	app.Put("/update/:uuid", userhandler.Put)
-	app.Patch("/patch/:uuid", userhandler.Patch)
+	app.Get("/availability", availabilityLimiter, userhandler.Availability)
+	app.Get("/users", userhandler.Admin, userhandler.List)
+	app.Get("/users/changes", userhandler.Changes)
+	app.Get("/users/username/:username", userhandler.GetByUsername)
+	app.Get("/users/email/:email", availabilityLimiter, userhandler.GetByEmail)
+	app.Get("/users/:uuid", userhandler.Get)
+	app.Post("/login", userhandler.Login)
+	app.Post("/login/mfa", userhandler.LoginMfa)
+	app.Post("/token/refresh", userhandler.Refresh)
+	app.Post("/logout", userhandler.Logout)
+	app.Post("/verify-email", userhandler.VerifyEmail)
+	app.Post("/verify-email/resend", userhandler.ResendVerification)
+	app.Post("/password/forgot", userhandler.ForgotPassword)
+	app.Post("/password/reset", userhandler.ResetPassword)
+	app.Post("/mfa/totp/setup", userhandler.SetupTotp)
+	app.Post("/mfa/totp/confirm", userhandler.ConfirmTotp)
+	app.Post("/mfa/totp/disable", userhandler.DisableTotp)
+	app.Post("/passkeys/register/begin", userhandler.BeginPasskeyRegistration)
+	app.Post("/passkeys/register/finish", userhandler.FinishPasskeyRegistration)
+	app.Post("/passkeys/login/begin", userhandler.BeginPasskeyLogin)
+	app.Post("/passkeys/login/finish", userhandler.FinishPasskeyLogin)
+	app.Get("/passkeys", userhandler.TokenValidate, userhandler.Passkeys)
+	app.Delete("/passkeys/:id", userhandler.TokenValidate, userhandler.DeletePasskey)
+	app.Get("/sessions", userhandler.Sessions)
+	app.Delete("/sessions/:id", userhandler.DeleteSession)
+	app.Get("/admin/jobs/dead", userhandler.DeadJobs)
+	app.Post("/admin/jobs/dead/:id/redrive", userhandler.RedriveJob)
+	app.Post("/admin/users/:uuid/unlock", userhandler.UnlockUser)
+	app.Post("/admin/ips/:ip/unlock", userhandler.UnlockIP)
+	app.Post("/admin/webhooks", userhandler.CreateWebhook)
+	app.Get("/admin/webhooks", userhandler.Webhooks)
+	app.Get("/admin/webhooks/:id", userhandler.GetWebhook)
+	app.Put("/admin/webhooks/:id", userhandler.UpdateWebhook)
+	app.Delete("/admin/webhooks/:id", userhandler.DeleteWebhook)
+	app.Get("/admin/webhooks/:id/deliveries", userhandler.WebhookDeliveries)
+	app.Get("/admin/webhooks/:id/deliveries/:delivery", userhandler.WebhookDelivery)
+	app.Post("/admin/webhooks/:id/deliveries/:delivery/replay", userhandler.ReplayWebhookDelivery)
+	app.Delete("/remove/:uuid", userhandler.Owner, userhandler.Delete)
+	app.Put("/update/:uuid", userhandler.Owner, userhandler.Put)
+	app.Patch("/patch/:uuid", userhandler.Owner, userhandler.Patch)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "3000"
 	}
-	app.Listen(":" + port)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := app.Listen(":" + port); err != nil {
+			log.Println(err)
+		}
+		stop()
+	}()
+	<-ctx.Done()
+
+	timeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil {
+		timeout = 30 * time.Second
+	}
+	log.Printf("shutting down, waiting up to %s\n", timeout)
+	deadline, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
+	// stop accepting requests and wait for the in-flight ones
+	closed := make(chan struct{})
+	go func() {
+		if err := app.Shutdown(); err != nil {
+			log.Println(err)
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-deadline.Done():
+		log.Println("requests still in flight at shutdown deadline")
+	}
+	stopBackground()
+	loops.Wait()
+	if left := pool.Shutdown(deadline); len(left) > 0 {
+		log.Printf("persisting %d unfinished jobs\n", len(left))
+		if err := jobservice.Persist(left); err != nil {
+			log.Println(err)
+		}
+	}
+	if err := bus.Close(); err != nil {
+		log.Println(err)
+	}
+	if err := repository.Close(); err != nil {
+		log.Println(err)
+	}
 }
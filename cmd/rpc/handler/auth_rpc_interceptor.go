@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/koalachatapp/user/internal/core/domain"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ownedRequest is a request about the user named by its uuid.
+type ownedRequest interface {
+	GetUuid() string
+}
+
+// claimsKey is the context key of the claims of the caller.
+type claimsKey struct{}
+
+// Authenticate is the unary interceptor guarding the server the way the
+// REST routes are guarded: Register is open, every other call needs a
+// bearer token in the authorization metadata, List needs the admin scope
+// and Update, Patch and Delete need the caller to be the user or an admin.
+// The actor of the call is the subject of the token, whose claims are kept
+// in the context of the call.
+func (h *RpcHandler) Authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	ctx = traced(ctx)
+	if info.FullMethod == domain.UserService_Register_FullMethodName {
+		return next(ctx, req)
+	}
+	claims, err := h.verify(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	switch info.FullMethod {
+	case domain.UserService_List_FullMethodName:
+		if !claims.HasScope(h.adminScope) {
+			return nil, toStatus(errs.Forbidden("Forbidden"))
+		}
+	case domain.UserService_Update_FullMethodName, domain.UserService_Patch_FullMethodName, domain.UserService_Delete_FullMethodName:
+		owned, ok := req.(ownedRequest)
+		if !ok || claims.Subject != owned.GetUuid() && !claims.HasScope(h.adminScope) {
+			return nil, toStatus(errs.Forbidden("Forbidden"))
+		}
+	}
+	trace := entity.TraceFrom(ctx)
+	trace.Actor = claims.Subject
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	return next(entity.WithTrace(ctx, trace), req)
+}
+
+// owns tells whether the caller is the user uuid or holds the admin scope.
+func (h *RpcHandler) owns(ctx context.Context, uuid string) bool {
+	claims, ok := ctx.Value(claimsKey{}).(entity.ClaimsEntity)
+	return ok && (claims.Subject == uuid || claims.HasScope(h.adminScope))
+}
+
+// verify checks the bearer token in the authorization metadata of the call.
+func (h *RpcHandler) verify(ctx context.Context) (entity.ClaimsEntity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get("authorization")
+	if len(auth) == 0 || auth[0] == "" {
+		return entity.ClaimsEntity{}, errs.Unauthorized("Not Authorized")
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(auth[0], "Bearer "))
+	if !strings.HasPrefix(auth[0], "Bearer ") || token == "" {
+		return entity.ClaimsEntity{}, errs.Unauthorized("Invalid Authorization")
+	}
+	claims, err := h.verifier.Verify(ctx, token)
+	if err != nil {
+		return entity.ClaimsEntity{}, errs.Unauthorized("Invalid Authorization")
+	}
+	return claims, nil
+}
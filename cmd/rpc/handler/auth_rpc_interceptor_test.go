@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/koalachatapp/user/internal/core/domain"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tokenVerifier knows a fixed set of tokens.
+type tokenVerifier map[string]entity.ClaimsEntity
+
+func (v tokenVerifier) Verify(ctx context.Context, token string) (entity.ClaimsEntity, error) {
+	claims, ok := v[token]
+	if !ok {
+		return entity.ClaimsEntity{}, errors.New("unknown token")
+	}
+	return claims, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	h := NewRpcHandler(nil, tokenVerifier{
+		"user":  {Subject: "u1"},
+		"admin": {Subject: "a1", Scopes: []string{"admin"}},
+	})
+	tests := []struct {
+		name   string
+		method string
+		req    interface{}
+		auth   string
+		code   codes.Code
+		actor  string
+	}{
+		{"register is open", domain.UserService_Register_FullMethodName, &domain.RegisterRequest{}, "", codes.OK, ""},
+		{"no token", domain.UserService_Get_FullMethodName, &domain.GetRequest{}, "", codes.Unauthenticated, ""},
+		{"not bearer", domain.UserService_Get_FullMethodName, &domain.GetRequest{}, "Basic user", codes.Unauthenticated, ""},
+		{"unknown token", domain.UserService_Get_FullMethodName, &domain.GetRequest{}, "Bearer nope", codes.Unauthenticated, ""},
+		{"get", domain.UserService_Get_FullMethodName, &domain.GetRequest{}, "Bearer user", codes.OK, "u1"},
+		{"list as user", domain.UserService_List_FullMethodName, &domain.ListRequest{}, "Bearer user", codes.PermissionDenied, ""},
+		{"list as admin", domain.UserService_List_FullMethodName, &domain.ListRequest{}, "Bearer admin", codes.OK, "a1"},
+		{"update self", domain.UserService_Update_FullMethodName, &domain.UpdateRequest{Uuid: "u1"}, "Bearer user", codes.OK, "u1"},
+		{"patch other", domain.UserService_Patch_FullMethodName, &domain.PatchRequest{Uuid: "u2"}, "Bearer user", codes.PermissionDenied, ""},
+		{"delete other as admin", domain.UserService_Delete_FullMethodName, &domain.DeleteRequest{Uuid: "u2"}, "Bearer admin", codes.OK, "a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a forged actor must not reach the service
+			md := metadata.Pairs("x-actor", "forged")
+			if tt.auth != "" {
+				md.Set("authorization", tt.auth)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			var actor string
+			_, err := h.Authenticate(ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				actor = entity.TraceFrom(ctx).Actor
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code = %s, want %s", code, tt.code)
+			}
+			if actor != tt.actor {
+				t.Errorf("actor = %q, want %q", actor, tt.actor)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"context"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/koalachatapp/user/internal/core/domain"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"github.com/koalachatapp/user/internal/errmap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type RpcHandler struct {
+	domain.UnimplementedUserServiceServer
+	service    port.UserService
+	verifier   port.TokenVerifier
+	adminScope string
+}
+
+// NewRpcHandler serves service to callers verified by verifier, see
+// Authenticate. ADMIN_SCOPE names the scope of admins (default "admin").
+func NewRpcHandler(service port.UserService, verifier port.TokenVerifier) *RpcHandler {
+	adminScope := os.Getenv("ADMIN_SCOPE")
+	if adminScope == "" {
+		adminScope = "admin"
+	}
+	return &RpcHandler{
+		service:    service,
+		verifier:   verifier,
+		adminScope: adminScope,
+	}
+}
+
+func (h *RpcHandler) Register(ctx context.Context, req *domain.RegisterRequest) (*domain.RegisterResponse, error) {
+	uuid, err := h.service.Register(ctx, toEntity(req.GetUser()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &domain.RegisterResponse{Uuid: uuid}, nil
+}
+
+func (h *RpcHandler) Get(ctx context.Context, req *domain.GetRequest) (*domain.UserDomain, error) {
+	var user entity.UserProfileEntity
+	var err error
+	switch key := req.GetKey().(type) {
+	case *domain.GetRequest_Uuid:
+		user, err = h.service.Get(key.Uuid)
+	case *domain.GetRequest_Username:
+		user, err = h.service.GetByUsername(key.Username)
+	case *domain.GetRequest_Email:
+		user, err = h.service.GetByEmail(key.Email)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "one of uuid, username or email is required")
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	// only the user and admins see the email
+	if !h.owns(ctx, user.Uuid) {
+		return toPublicDomain(user.Public()), nil
+	}
+	return toDomain(user), nil
+}
+
+func (h *RpcHandler) Update(ctx context.Context, req *domain.UpdateRequest) (*emptypb.Empty, error) {
+	if err := h.service.Update(ctx, req.GetUuid(), toEntity(req.GetUser())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (h *RpcHandler) Patch(ctx context.Context, req *domain.PatchRequest) (*emptypb.Empty, error) {
+	if err := h.service.Patch(ctx, req.GetUuid(), toEntity(req.GetUser())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (h *RpcHandler) Delete(ctx context.Context, req *domain.DeleteRequest) (*emptypb.Empty, error) {
+	if err := h.service.Delete(ctx, req.GetUuid()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (h *RpcHandler) List(ctx context.Context, req *domain.ListRequest) (*domain.ListResponse, error) {
+	query := entity.ListQuery{
+		Cursor: req.GetCursor(),
+		Limit:  int(req.GetLimit()),
+		Prefix: req.GetQ(),
+	}
+	if req.CreatedAfter != nil {
+		query.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		query.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+	page, err := h.service.List(query)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	res := &domain.ListResponse{
+		Users:      make([]*domain.UserDomain, 0, len(page.Users)),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	for _, user := range page.Users {
+		res.Users = append(res.Users, toDomain(user))
+	}
+	return res, nil
+}
+
+func toEntity(user *domain.UserDomain) entity.UserEntity {
+	return entity.UserEntity{
+		Username: user.GetUsername(),
+		Name:     user.GetName(),
+		Email:    user.GetEmail(),
+		Password: user.GetPassword(),
+	}
+}
+
+func toDomain(user entity.UserProfileEntity) *domain.UserDomain {
+	return &domain.UserDomain{
+		Uuid:      user.Uuid,
+		Username:  user.Username,
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+	}
+}
+
+func toPublicDomain(user entity.PublicProfileEntity) *domain.UserDomain {
+	return &domain.UserDomain{
+		Uuid:      user.Uuid,
+		Username:  user.Username,
+		Name:      user.Name,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+	}
+}
+
+// traced stores the x-correlation-id metadata of the call in ctx. The
+// actor is set by Authenticate from the verified token.
+func traced(ctx context.Context) context.Context {
+	var trace entity.TraceEntity
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if id := md.Get("x-correlation-id"); len(id) > 0 {
+			trace.CorrelationID = id[0]
+		}
+	}
+	if trace.CorrelationID == "" {
+		trace.CorrelationID = uuid.New().String()
+	}
+	return entity.WithTrace(ctx, trace)
+}
+
+// toStatus maps service errors onto gRPC status codes.
+func toStatus(err error) error {
+	return errmap.GRPC(err)
+}
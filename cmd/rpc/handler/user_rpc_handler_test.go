@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/koalachatapp/user/internal/core/domain"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// profileService knows a single user.
+type profileService struct {
+	port.UserService
+	user entity.UserProfileEntity
+}
+
+func (s profileService) Get(uuid string) (entity.UserProfileEntity, error) {
+	return s.user, nil
+}
+
+func TestGetHidesEmail(t *testing.T) {
+	service := profileService{user: entity.UserProfileEntity{Uuid: "u1", Username: "koala", Email: "koala@example.com"}}
+	h := NewRpcHandler(service, tokenVerifier{
+		"owner": {Subject: "u1"},
+		"other": {Subject: "u2"},
+		"admin": {Subject: "a1", Scopes: []string{"admin"}},
+	})
+	for token, email := range map[string]string{"owner": "koala@example.com", "other": "", "admin": "koala@example.com"} {
+		t.Run(token, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+			res, err := h.Authenticate(ctx, &domain.GetRequest{Key: &domain.GetRequest_Uuid{Uuid: "u1"}},
+				&grpc.UnaryServerInfo{FullMethod: domain.UserService_Get_FullMethodName},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					return h.Get(ctx, req.(*domain.GetRequest))
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			user := res.(*domain.UserDomain)
+			if user.GetUsername() != "koala" || user.GetEmail() != email {
+				t.Errorf("user %v, want email %q", user, email)
+			}
+		})
+	}
+}
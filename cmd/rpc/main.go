@@ -1,5 +1,142 @@
 package main
 
+import (
+	"context"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/koalachatapp/user/cmd/rpc/handler"
+	"github.com/koalachatapp/user/internal/core/domain"
+	"github.com/koalachatapp/user/internal/core/service"
+	"github.com/koalachatapp/user/internal/encryption"
+	"github.com/koalachatapp/user/internal/hasher"
+	"github.com/koalachatapp/user/internal/mailer"
+	"github.com/koalachatapp/user/internal/policy"
+	"github.com/koalachatapp/user/internal/publisher"
+	"github.com/koalachatapp/user/internal/repository"
+	"github.com/koalachatapp/user/internal/token"
+	"github.com/koalachatapp/user/internal/validation"
+	"github.com/koalachatapp/user/internal/webhook"
+	"github.com/koalachatapp/user/internal/worker"
+	"google.golang.org/grpc"
+)
+
 func main() {
+	rand.Seed(time.Now().Unix())
+	// repository
+	userrepo := repository.NewUserRepository()
+
+	bus := publisher.NewEventPublisher()
+
+	// worker
+	pool := worker.NewPool()
+	pool.Start(context.Background())
+
+	mfabox, err := encryption.NewSecretBox("MFA_ENCRYPTION_KEY")
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhookbox, err := encryption.NewSecretBox("WEBHOOK_ENCRYPTION_KEY")
+	if err != nil {
+		log.Fatal(err)
+	}
+	jobbox, err := encryption.NewSecretBox("JOB_ENCRYPTION_KEY")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// service
+	sessionservice := service.NewSessionService(repository.NewSessionRepository(), token.NewJwtIssuer())
+	jobservice := service.NewJobService(repository.NewJobRepository(), pool, jobbox)
+	lockoutservice := service.NewLockoutService(repository.NewLockoutRepository(), repository.NewOutboxRepository(), pool)
+	mfaservice := service.NewMfaService(repository.NewMfaRepository(), userrepo, repository.NewMfaChallengeRepository(), mfabox, sessionservice, lockoutservice)
+	userservice := service.NewUserService(userrepo, pool, hasher.NewPasswordHasher(), policy.NewPasswordPolicy(), validation.NewValidator(), sessionservice, mfaservice, lockoutservice, repository.NewVerificationRepository(), repository.NewPasswordResetRepository(), mailer.NewMailer(), repository.NewAvailabilityRepository(), repository.NewRedisClient())
+	webhookservice := service.NewWebhookService(repository.NewWebhookRepository(), webhook.NewHttpSender(), webhookbox, validation.NewValidator())
+	// the relay may run here, so it feeds the change feed served by the
+	// REST instances too
+	changefeed := service.NewChangeFeed(repository.NewChangeLogRepository())
+	relay := service.NewOutboxRelay(repository.NewOutboxRepository(), publisher.NewFanoutPublisher(bus, webhookservice, changefeed), pool)
+	background, stopBackground := context.WithCancel(context.Background())
+	var loops sync.WaitGroup
+	loops.Add(3)
+	go func() {
+		defer loops.Done()
+		jobservice.Run(background)
+	}()
+	go func() {
+		defer loops.Done()
+		relay.Run(background)
+	}()
+	go func() {
+		defer loops.Done()
+		webhookservice.Run(background)
+	}()
+
+	// handler
+	userhandler := handler.NewRpcHandler(userservice, token.NewTokenVerifier())
+
+	server := grpc.NewServer(grpc.UnaryInterceptor(userhandler.Authenticate))
+	domain.RegisterUserServiceServer(server, userhandler)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3030"
+	}
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	log.Println("gRPC server listening on", lis.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Println(err)
+		}
+		stop()
+	}()
+	<-ctx.Done()
+
+	timeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil {
+		timeout = 30 * time.Second
+	}
+	log.Printf("shutting down, waiting up to %s\n", timeout)
+	deadline, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
+	// stop accepting calls and wait for the in-flight ones
+	closed := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-deadline.Done():
+		log.Println("calls still in flight at shutdown deadline")
+		server.Stop()
+	}
+	stopBackground()
+	loops.Wait()
+	if left := pool.Shutdown(deadline); len(left) > 0 {
+		log.Printf("persisting %d unfinished jobs\n", len(left))
+		if err := jobservice.Persist(left); err != nil {
+			log.Println(err)
+		}
+	}
+	if err := bus.Close(); err != nil {
+		log.Println(err)
+	}
+	if err := repository.Close(); err != nil {
+		log.Println(err)
+	}
 }
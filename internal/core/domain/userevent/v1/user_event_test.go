@@ -0,0 +1,114 @@
+package usereventv1
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// The testdata holds events as v1 producers wrote them: <name>.binpb is the
+// payload and <name>.json what a consumer reads from it. They are never
+// regenerated; a schema change that cannot read them breaks consumers.
+
+func TestGoldenPayloads(t *testing.T) {
+	payloads, err := filepath.Glob("testdata/*.binpb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) == 0 {
+		t.Fatal("no golden payloads")
+	}
+	for _, path := range payloads {
+		name := strings.TrimSuffix(filepath.Base(path), ".binpb")
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := &UserEvent{}
+			if err := proto.Unmarshal(b, got); err != nil {
+				t.Fatalf("payload does not decode: %v", err)
+			}
+			if unknown := unknownFields(got.ProtoReflect()); unknown != "" {
+				t.Errorf("fields %s of the payload are no longer known", unknown)
+			}
+			j, err := os.ReadFile(strings.TrimSuffix(path, ".binpb") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &UserEvent{}
+			if err := protojson.Unmarshal(j, want); err != nil {
+				t.Fatalf("expected event does not decode: %v", err)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("payload decodes to %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestGoldenFields(t *testing.T) {
+	f, err := os.Open("testdata/fields.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		want := strings.Fields(line)
+		if len(want) < 4 {
+			t.Fatalf("malformed line %q", line)
+		}
+		full := protoreflect.FullName(want[0])
+		message, err := protoregistry.GlobalFiles.FindDescriptorByName(full.Parent())
+		if err != nil {
+			t.Errorf("%s: message removed", full.Parent())
+			continue
+		}
+		field := message.(protoreflect.MessageDescriptor).Fields().ByName(full.Name())
+		if field == nil {
+			t.Errorf("%s: field removed", full)
+			continue
+		}
+		got := []string{string(field.FullName()), strconv.Itoa(int(field.Number())), field.Kind().String(), field.Cardinality().String()}
+		if field.Message() != nil {
+			got = append(got, string(field.Message().FullName()))
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("field changed from %q to %q", strings.Join(want, " "), strings.Join(got, " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// unknownFields names the messages of m holding fields the schema does not
+// know.
+func unknownFields(m protoreflect.Message) string {
+	var names []string
+	if len(m.GetUnknown()) > 0 {
+		names = append(names, string(m.Descriptor().FullName()))
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			if unknown := unknownFields(v.Message()); unknown != "" {
+				names = append(names, unknown)
+			}
+		}
+		return true
+	})
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,16 @@
+package entity
+
+// AvailabilityEntity answers GET /availability. A field is nil when it was
+// not asked about.
+type AvailabilityEntity struct {
+	Username *FieldAvailabilityEntity `json:"username,omitempty"`
+	Email    *FieldAvailabilityEntity `json:"email,omitempty"`
+}
+
+// FieldAvailabilityEntity tells whether Value is free. Suggestions are free
+// alternatives to a taken username.
+type FieldAvailabilityEntity struct {
+	Value       string   `json:"value"`
+	Available   bool     `json:"available"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
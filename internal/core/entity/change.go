@@ -0,0 +1,15 @@
+package entity
+
+import "encoding/json"
+
+// ChangeEntity is a user event on the change feed. ID orders the feed and
+// resumes it; Event is the CloudEvent in JSON. A Reset change carries no
+// event and tells the subscriber that changes were missed, so its copies
+// of the users it follows need to be fetched again.
+type ChangeEntity struct {
+	ID      string          `json:"id,omitempty"`
+	Subject string          `json:"-"`
+	Type    string          `json:"-"`
+	Event   json.RawMessage `json:"event,omitempty"`
+	Reset   bool            `json:"-"`
+}
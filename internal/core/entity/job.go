@@ -0,0 +1,102 @@
+package entity
+
+import "time"
+
+const (
+	JobPersistUser        = "persist_user"
+	JobUpdatePassword     = "update_password"
+	JobInvalidateCache    = "invalidate_cache"
+	JobPublishEvent       = "publish_event"
+	JobSendVerification   = "send_verification"
+	JobResendVerification = "resend_verification"
+	JobSendPasswordReset  = "send_password_reset"
+)
+
+// JobEntity is the stored form of a job, so a failed job can be persisted
+// and retried after a restart. Payloads carry password hashes, so they are
+// stored sealed and never listed.
+type JobEntity struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Kind      string    `json:"kind" gorm:"not null"`
+	Payload   []byte    `json:"-" gorm:"not null"`
+	Sealed    bool      `json:"-" gorm:"not null;default:false"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	NextRunAt time.Time `json:"next_run_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	// Stored is set on jobs loaded back from the retry queue.
+	Stored bool `json:"-" gorm:"-"`
+}
+
+func (JobEntity) TableName() string {
+	return "retry_jobs"
+}
+
+// DeadJobEntity is a job that exhausted its attempts.
+type DeadJobEntity struct {
+	JobEntity
+	DeadAt time.Time `json:"dead_at"`
+}
+
+func (DeadJobEntity) TableName() string {
+	return "dead_letter_jobs"
+}
+
+// PersistUserJob writes a registered, updated or patched user together with
+// its outbox event. Reverify asks for a verification mail to the new email
+// once it is stored.
+type PersistUserJob struct {
+	Op       string       `json:"op"`
+	Uuid     string       `json:"uuid"`
+	User     UserEntity   `json:"user"`
+	Event    OutboxEntity `json:"event"`
+	Reverify bool         `json:"reverify"`
+}
+
+func (PersistUserJob) Kind() string { return JobPersistUser }
+
+// UpdatePasswordJob replaces a stored password hash.
+type UpdatePasswordJob struct {
+	Uuid string `json:"uuid"`
+	Hash string `json:"hash"`
+}
+
+func (UpdatePasswordJob) Kind() string { return JobUpdatePassword }
+
+// InvalidateCacheJob drops the cached copy of a user.
+type InvalidateCacheJob struct {
+	Uuid string `json:"uuid"`
+}
+
+func (InvalidateCacheJob) Kind() string { return JobInvalidateCache }
+
+// PublishEventJob relays pending outbox messages right away instead of
+// waiting for the next poll.
+type PublishEventJob struct{}
+
+func (PublishEventJob) Kind() string { return JobPublishEvent }
+
+// SendVerificationJob mails a fresh verification link to Email. The token is
+// created when the job runs, so it is never stored with the job.
+type SendVerificationJob struct {
+	Uuid  string `json:"uuid"`
+	Email string `json:"email"`
+}
+
+func (SendVerificationJob) Kind() string { return JobSendVerification }
+
+// ResendVerificationJob mails a new verification link to the account
+// registered with Email, if there is one and it is not verified yet.
+type ResendVerificationJob struct {
+	Email string `json:"email"`
+}
+
+func (ResendVerificationJob) Kind() string { return JobResendVerification }
+
+// SendPasswordResetJob mails a reset link to the account registered with
+// Email, if there is one.
+type SendPasswordResetJob struct {
+	Email string `json:"email"`
+}
+
+func (SendPasswordResetJob) Kind() string { return JobSendPasswordReset }
@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// ListQuery selects a page of users. Prefix matches the start of the
+// username or email; the creation window is [CreatedAfter, CreatedBefore).
+type ListQuery struct {
+	Cursor        string
+	Limit         int
+	Prefix        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Key is Cursor decoded by the service for the repository.
+	Key *PageKey
+}
+
+// PageKey is a position in the (created_at, uuid) ordering of users. A
+// backward key selects the rows before it instead of after it.
+type PageKey struct {
+	CreatedAt time.Time `json:"t"`
+	Uuid      string    `json:"u"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+type ListPage struct {
+	Users      []UserProfileEntity `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	PrevCursor string              `json:"prev_cursor,omitempty"`
+}
@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// SecurityEventEntity is published, as a securityevent.v1.LoginLock
+// CloudEvent, when logins get locked or unlocked. Uuid is empty for a lock
+// on an IP or on a login naming no account.
+type SecurityEventEntity struct {
+	Method   string
+	Uuid     string
+	IP       string
+	Failures int64
+	Until    time.Time
+	Actor    string
+}
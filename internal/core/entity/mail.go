@@ -0,0 +1,7 @@
+package entity
+
+type MailEntity struct {
+	To      string
+	Subject string
+	Body    string
+}
@@ -0,0 +1,45 @@
+package entity
+
+import "time"
+
+// MfaEntity holds the TOTP factor of a user. Secret is encrypted; LastStep
+// is the last accepted time step so a code cannot be replayed.
+type MfaEntity struct {
+	Uuid      string    `json:"uuid" gorm:"primaryKey"`
+	Secret    []byte    `json:"-" gorm:"not null"`
+	Enabled   bool      `json:"enabled" gorm:"not null;default:false"`
+	LastStep  int64     `json:"-" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (MfaEntity) TableName() string {
+	return "user_mfa"
+}
+
+// RecoveryCodeEntity is the hash of a one-time recovery code.
+type RecoveryCodeEntity struct {
+	Hash string `gorm:"primaryKey"`
+	Uuid string `gorm:"not null;index"`
+}
+
+func (RecoveryCodeEntity) TableName() string {
+	return "user_mfa_recovery_codes"
+}
+
+// TotpSetupEntity is handed out once when enrolling an authenticator app.
+type TotpSetupEntity struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode []byte `json:"qr_png"`
+}
+
+// MfaCodeEntity is the body of the MFA confirm and disable requests.
+type MfaCodeEntity struct {
+	Code string `json:"code" form:"code"`
+}
+
+// MfaLoginEntity is the body of the second login step.
+type MfaLoginEntity struct {
+	MfaToken string `json:"mfa_token" form:"mfa_token"`
+	Code     string `json:"code" form:"code"`
+}
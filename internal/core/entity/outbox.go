@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+// OutboxEntity is a message waiting to be published. It is written in the
+// same transaction as the change it describes and relayed in ID order.
+type OutboxEntity struct {
+	ID      uint64 `gorm:"primaryKey;autoIncrement"`
+	Topic   string `gorm:"not null"`
+	Payload []byte `gorm:"not null"`
+	// Headers holds the CloudEvents attributes of a user event in Kafka
+	// binary mode, as a JSON object of header names to values.
+	Headers []byte
+	// Key is the uuid of the user a user event is about. Events with the
+	// same key go to the same partition, numbered by Sequence from 1.
+	Key       string
+	Sequence  uint64
+	CreatedAt time.Time
+	SentAt    *time.Time `gorm:"index"`
+}
+
+func (OutboxEntity) TableName() string {
+	return "outbox"
+}
+
+// UserEventSequenceEntity is the number of the last event keyed by Uuid.
+type UserEventSequenceEntity struct {
+	Uuid     string `gorm:"primaryKey"`
+	Sequence uint64 `gorm:"not null"`
+}
+
+func (UserEventSequenceEntity) TableName() string {
+	return "user_event_sequences"
+}
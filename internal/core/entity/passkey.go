@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PasskeyEntity is a WebAuthn credential registered to a user. ID is the
+// base64url encoded credential ID.
+type PasskeyEntity struct {
+	ID              string     `json:"id" gorm:"primaryKey"`
+	Uuid            string     `json:"-" gorm:"not null;index"`
+	Name            string     `json:"name"`
+	PublicKey       []byte     `json:"-" gorm:"not null"`
+	AttestationType string     `json:"-"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-"`
+	Transports      string     `json:"transports"`
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+}
+
+func (PasskeyEntity) TableName() string {
+	return "user_passkeys"
+}
+
+// CeremonyEntity starts a WebAuthn ceremony: Options go to
+// navigator.credentials and ID comes back with the result.
+type CeremonyEntity struct {
+	ID      string          `json:"ceremony_id"`
+	Options json.RawMessage `json:"options"`
+}
+
+// CeremonyResultEntity is the body finishing a WebAuthn ceremony.
+// Credential is the PublicKeyCredential as returned by the browser.
+type CeremonyResultEntity struct {
+	ID         string          `json:"ceremony_id"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+}
@@ -0,0 +1,12 @@
+package entity
+
+// ForgotPasswordEntity is the body of POST /password/forgot.
+type ForgotPasswordEntity struct {
+	Email string `json:"email" form:"email" validate:"required,max=254,email"`
+}
+
+// ResetPasswordEntity is the body of POST /password/reset.
+type ResetPasswordEntity struct {
+	Token    string `json:"token" form:"token" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required"`
+}
@@ -0,0 +1,7 @@
+package entity
+
+// PolicyViolationEntity is one password policy rule a password broke.
+type PolicyViolationEntity struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// ClientEntity describes the device a request came from.
+type ClientEntity struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionEntity is one login of a user on one device. Every refresh token
+// rotated out of a session belongs to the same token family.
+type SessionEntity struct {
+	ID         string    `json:"id"`
+	Uuid       string    `json:"uuid"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+	TokenHash  string    `json:"-"`
+}
+
+type RefreshEntity struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+type LoginEntity struct {
+	Login    string `json:"login" form:"login"`
+	Password string `json:"password" form:"password"`
+}
+
+// TokenEntity is the result of a login. When the account has MFA enabled
+// the first step only yields MfaToken, to be exchanged together with a code.
+type TokenEntity struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MfaToken     string `json:"mfa_token,omitempty"`
+}
+
+// ClaimsEntity is what a verified access token says about its bearer.
+type ClaimsEntity struct {
+	Subject   string
+	SessionID string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+func (c ClaimsEntity) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "context"
+
+// TraceEntity tells who caused a change and which request it belongs to.
+// Events carry it so consumers can follow a change back to its origin.
+type TraceEntity struct {
+	Actor         string
+	CorrelationID string
+}
+
+type traceKey struct{}
+
+func WithTrace(ctx context.Context, trace TraceEntity) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// TraceFrom returns the trace stored in ctx, or an empty one.
+func TraceFrom(ctx context.Context) TraceEntity {
+	trace, _ := ctx.Value(traceKey{}).(TraceEntity)
+	return trace
+}
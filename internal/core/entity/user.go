@@ -1,9 +1,55 @@
 package entity
 
+import "time"
+
 type UserEntity struct {
-	Email    string `json:"email" form:"email" gorm:"unique"`
-	Name     string `json:"name" form:"name"`
-	Password string `json:"password" form:"password"`
-	Username string `json:"username" form:"username" gorm:"unique"`
-	Uuid     string `json:"uuid" gorm:"primaryKey;unique"`
+	Email    string `json:"email" form:"email" gorm:"unique" validate:"required,max=254,email"`
+	Name     string `json:"name" form:"name" validate:"required,max=100,name"`
+	Password string `json:"password" form:"password" validate:"required"`
+	Username string `json:"username" form:"username" gorm:"unique" validate:"required,min=3,max=32,username"`
+	Uuid     string `json:"uuid" gorm:"primaryKey;unique;index:idx_user_created_uuid,priority:2"`
+	// Verified is set once the owner proved control of Email; it is never
+	// taken from a request body.
+	Verified  bool      `json:"verified" form:"-" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;not null;default:CURRENT_TIMESTAMP;index:idx_user_created_uuid,priority:1"`
+}
+
+// UserProfileEntity is what the service hands out about a user; it never
+// carries the password hash.
+type UserProfileEntity struct {
+	Uuid      string    `json:"uuid"`
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Verified  bool      `json:"verified"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (u UserEntity) Profile() UserProfileEntity {
+	return UserProfileEntity{
+		Uuid:      u.Uuid,
+		Username:  u.Username,
+		Name:      u.Name,
+		Email:     u.Email,
+		Verified:  u.Verified,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+// PublicProfileEntity is what any signed-in user may see of another user:
+// neither the email nor whether it is verified.
+type PublicProfileEntity struct {
+	Uuid      string    `json:"uuid"`
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (u UserProfileEntity) Public() PublicProfileEntity {
+	return PublicProfileEntity{
+		Uuid:      u.Uuid,
+		Username:  u.Username,
+		Name:      u.Name,
+		CreatedAt: u.CreatedAt,
+	}
 }
@@ -1,6 +0,0 @@
-package entity
-
-type UserEventEntity struct {
-	Data   UserEntity
-	Method string
-}
@@ -0,0 +1,18 @@
+package entity
+
+// VerifyEmailEntity is the body of POST /verify-email.
+type VerifyEmailEntity struct {
+	Token string `json:"token" form:"token"`
+}
+
+// ResendVerificationEntity is the body of POST /verify-email/resend.
+type ResendVerificationEntity struct {
+	Email string `json:"email" form:"email" validate:"required,max=254,email"`
+}
+
+// VerificationEntity is what a pending email verification token stands
+// for. The token is only valid while the account still has that email.
+type VerificationEntity struct {
+	Uuid  string `json:"uuid"`
+	Email string `json:"email"`
+}
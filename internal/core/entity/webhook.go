@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookEntity is an endpoint subscribed to user events. Events lists the
+// CloudEvents types it receives, every user lifecycle event when empty.
+// Secret is only set on the response creating it with a generated secret;
+// it is stored sealed. Failures counts the deliveries in a row that failed.
+type WebhookEntity struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	URL          string     `json:"url" gorm:"not null"`
+	Events       []string   `json:"events" gorm:"serializer:json"`
+	Secret       string     `json:"secret,omitempty" gorm:"-"`
+	SealedSecret []byte     `json:"-" gorm:"not null"`
+	Enabled      bool       `json:"enabled"`
+	Failures     int        `json:"failures"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (WebhookEntity) TableName() string {
+	return "webhooks"
+}
+
+// WebhookRequestEntity is the body creating or replacing a webhook. An
+// empty Secret is generated on create and left as it was on update; a
+// missing Enabled is true on create and left as it was on update.
+type WebhookRequestEntity struct {
+	URL     string   `json:"url" validate:"required,max=2048,url"`
+	Events  []string `json:"events"`
+	Secret  string   `json:"secret" validate:"omitempty,min=16,max=256"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// WebhookDeliveryEntity is one event sent to one webhook. Payload is the
+// exact body posted; Replays counts how often it was queued again by hand.
+type WebhookDeliveryEntity struct {
+	ID             string                 `json:"id" gorm:"primaryKey"`
+	WebhookID      string                 `json:"webhook_id" gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	EventID        string                 `json:"event_id" gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	EventType      string                 `json:"event_type" gorm:"not null"`
+	Payload        json.RawMessage        `json:"payload" gorm:"not null"`
+	Status         string                 `json:"status" gorm:"not null;index"`
+	Attempts       int                    `json:"attempts"`
+	NextAttemptAt  time.Time              `json:"next_attempt_at" gorm:"index"`
+	LastStatusCode int                    `json:"last_status_code,omitempty"`
+	LastError      string                 `json:"last_error,omitempty"`
+	Replays        int                    `json:"replays"`
+	CreatedAt      time.Time              `json:"created_at"`
+	DeliveredAt    *time.Time             `json:"delivered_at,omitempty"`
+	Log            []WebhookAttemptEntity `json:"log,omitempty" gorm:"-"`
+}
+
+func (WebhookDeliveryEntity) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookAttemptEntity records one POST of a delivery. StatusCode is zero
+// when no response came back.
+type WebhookAttemptEntity struct {
+	ID         uint64    `json:"-" gorm:"primaryKey;autoIncrement"`
+	DeliveryID string    `json:"-" gorm:"not null;index"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	At         time.Time `json:"at"`
+}
+
+func (WebhookAttemptEntity) TableName() string {
+	return "webhook_attempts"
+}
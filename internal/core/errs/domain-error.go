@@ -0,0 +1,125 @@
+package errs
+
+import (
+	"errors"
+	"time"
+)
+
+// Kind classifies a domain error. Services only pick the kind; each
+// transport decides what it means on the wire.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindFailedPrecondition
+	KindTooManyRequests
+	KindUnavailable
+)
+
+// FieldError is one rule a field of the request broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is the error every service returns for an expected failure.
+// Message is safe to show to the caller; the cause, if any, is not.
+type Error struct {
+	Kind    Kind
+	Message string
+	// Fields lists the broken rules of a validation error.
+	Fields []FieldError
+	// RetryAfter tells a rate limited caller when to come back.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the sentinel of the same kind, so callers can test with
+// errors.Is(err, errs.ErrNotFound) whatever the message.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Kind == e.Kind
+}
+
+var (
+	ErrValidation         = &Error{Kind: KindValidation}
+	ErrUnauthorized       = &Error{Kind: KindUnauthorized}
+	ErrForbidden          = &Error{Kind: KindForbidden}
+	ErrNotFound           = &Error{Kind: KindNotFound}
+	ErrConflict           = &Error{Kind: KindConflict}
+	ErrFailedPrecondition = &Error{Kind: KindFailedPrecondition}
+	ErrTooManyRequests    = &Error{Kind: KindTooManyRequests}
+	ErrUnavailable        = &Error{Kind: KindUnavailable}
+)
+
+// Validation rejects a request, listing the fields at fault.
+func Validation(message string, fields ...FieldError) error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// Invalid rejects a single field.
+func Invalid(field string, rule string, message string) error {
+	return Validation(message, FieldError{Field: field, Rule: rule, Message: message})
+}
+
+func Unauthorized(message string) error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+func Forbidden(message string) error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+func NotFound(message string) error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+// Conflict rejects a request clashing with an existing resource, as a
+// taken username.
+func Conflict(message string) error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+// FailedPrecondition rejects a request the resource is in the wrong state
+// for, as confirming what is already confirmed.
+func FailedPrecondition(message string) error {
+	return &Error{Kind: KindFailedPrecondition, Message: message}
+}
+
+func TooManyRequests(message string, retryAfter time.Duration) error {
+	return &Error{Kind: KindTooManyRequests, Message: message, RetryAfter: retryAfter}
+}
+
+// Unavailable reports a dependency that failed, keeping cause for the logs.
+func Unavailable(message string, cause error) error {
+	return &Error{Kind: KindUnavailable, Message: message, Err: cause}
+}
+
+// Internal reports a failure the caller cannot do anything about.
+func Internal(message string, cause error) error {
+	return &Error{Kind: KindInternal, Message: message, Err: cause}
+}
+
+// As returns the domain error in err's chain. Any other error is
+// internal and its message is withheld.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Kind: KindInternal, Message: "internal error", Err: err}
+}
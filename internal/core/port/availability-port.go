@@ -0,0 +1,10 @@
+package port
+
+import "time"
+
+type AvailabilityCache interface {
+	// Get returns whether value of field is taken and whether that answer
+	// was cached at all.
+	Get(field string, value string) (bool, bool, error)
+	Set(field string, value string, taken bool, ttl time.Duration) error
+}
@@ -0,0 +1,36 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+// ChangeLog is a bounded log of user events shared by every instance. Ids
+// are assigned by the log and increase.
+type ChangeLog interface {
+	// Append adds change and evicts the oldest changes beyond max.
+	Append(change entity.ChangeEntity, max int64) (string, error)
+	// Last returns the id of the latest change, or "" for an empty log.
+	Last() (string, error)
+	// Since returns up to limit changes after id, oldest first. found is
+	// false when id is no longer in the log, so changes may be missing.
+	Since(id string, limit int64) ([]entity.ChangeEntity, bool, error)
+	// Tail waits up to block for changes after id.
+	Tail(ctx context.Context, id string, block time.Duration) ([]entity.ChangeEntity, error)
+}
+
+type ChangeFeed interface {
+	// Publish appends a user event to the log, so the outbox relay can fan
+	// out to the feed.
+	EventPublisher
+	// Run hands the changes appended to the log by any instance to the
+	// subscribers of this one until ctx is done.
+	Run(ctx context.Context)
+	// Subscribe streams the changes of uuids until ctx is done, first
+	// replaying those after lastEventID when it is set. The channel closes
+	// early when the subscriber falls behind; it may resume from the last
+	// id it got.
+	Subscribe(ctx context.Context, uuids []string, lastEventID string) (<-chan entity.ChangeEntity, error)
+}
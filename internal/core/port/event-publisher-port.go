@@ -0,0 +1,16 @@
+package port
+
+import (
+	"context"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+// EventPublisher delivers outbox messages to a message bus, on the topic
+// the message names.
+type EventPublisher interface {
+	// Publish returns once the bus accepted event. Events with the same key
+	// must reach consumers in the order they were published.
+	Publish(ctx context.Context, event entity.OutboxEntity) error
+	Close() error
+}
@@ -0,0 +1,39 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+type JobRepository interface {
+	// Schedule stores job for a retry at job.NextRunAt, replacing any
+	// earlier copy with the same ID.
+	Schedule(job entity.JobEntity) error
+	// Claim returns up to limit jobs that are due and hides them from other
+	// claimers for lease, so a crash mid-run only delays them.
+	Claim(limit int, lease time.Duration) ([]entity.JobEntity, error)
+	Complete(id string) error
+	// Bury moves job to the dead-letter store.
+	Bury(job entity.DeadJobEntity) error
+	DeadLetters(limit int) ([]entity.DeadJobEntity, error)
+	// Unbury removes a job from the dead-letter store and returns it.
+	Unbury(id string) (entity.JobEntity, bool, error)
+}
+
+type JobService interface {
+	// Run feeds due retries to the worker until ctx is done.
+	Run(ctx context.Context)
+	// Failed schedules job for another attempt with backoff, or buries it
+	// once it ran out of attempts.
+	Failed(job entity.JobEntity, err error)
+	// Succeeded forgets a job that had been persisted for retry.
+	Succeeded(job entity.JobEntity)
+	// Persist stores jobs cut off by a shutdown so they run again right
+	// away, without counting an attempt.
+	Persist(jobs []entity.JobEntity) error
+	DeadLetters(limit int) ([]entity.DeadJobEntity, error)
+	// Redrive puts a dead job back on the retry queue with fresh attempts.
+	Redrive(id string) error
+}
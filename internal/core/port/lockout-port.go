@@ -0,0 +1,26 @@
+package port
+
+import "time"
+
+type LockoutStore interface {
+	// Locked returns how long the longest lock on keys still lasts.
+	Locked(keys ...string) (time.Duration, error)
+	// Fail counts a failure against key within window and returns the
+	// count so far.
+	Fail(key string, window time.Duration) (int64, error)
+	Lock(key string, d time.Duration) error
+	// Clear drops the failures and lock of key, reporting whether it was
+	// locked.
+	Clear(key string) (bool, error)
+}
+
+type LockoutService interface {
+	// Check returns an errs.KindTooManyRequests error while the account or
+	// ip is locked. The account is uuid, or login when it names no account.
+	Check(uuid string, login string, ip string) error
+	Failed(uuid string, login string, ip string)
+	Succeeded(uuid string)
+	// UnlockUser and UnlockIP lift a lock on behalf of actor.
+	UnlockUser(uuid string, actor string) error
+	UnlockIP(ip string, actor string) error
+}
@@ -0,0 +1,11 @@
+package port
+
+import (
+	"context"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+type Mailer interface {
+	Send(ctx context.Context, mail entity.MailEntity) error
+}
@@ -0,0 +1,57 @@
+package port
+
+import (
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+type MfaRepository interface {
+	Get(uuid string) (entity.MfaEntity, bool, error)
+	// SavePending stores a factor that is not enabled yet, replacing an
+	// earlier pending one. It reports false when an enabled factor exists.
+	SavePending(mfa entity.MfaEntity) (bool, error)
+	// Enable turns the pending factor of uuid on and replaces its recovery
+	// codes.
+	Enable(uuid string, codeHashes []string) error
+	// Delete removes the factor and recovery codes of uuid.
+	Delete(uuid string) error
+	// UseStep records step as used, reporting false when it is not newer
+	// than the last used one.
+	UseStep(uuid string, step int64) (bool, error)
+	// UseRecoveryCode deletes the code, reporting whether it existed.
+	UseRecoveryCode(uuid string, hash string) (bool, error)
+}
+
+// MfaChallengeStore keeps the logins waiting for their second factor.
+type MfaChallengeStore interface {
+	// Create starts a challenge unless uuid already started maxChallenges
+	// within the current ttl, reporting whether it did.
+	Create(tokenHash string, uuid string, ttl time.Duration, maxChallenges int) (bool, error)
+	// Attempt counts a try against the challenge and returns its uuid, or
+	// false once it expired or ran out of tries.
+	Attempt(tokenHash string, maxAttempts int) (string, bool, error)
+	Delete(tokenHash string) error
+}
+
+// SecretBox encrypts secrets at rest.
+type SecretBox interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(ciphertext []byte) ([]byte, error)
+}
+
+type MfaService interface {
+	// SetupTotp creates a new pending secret for uuid.
+	SetupTotp(uuid string) (entity.TotpSetupEntity, error)
+	// ConfirmTotp enables the pending secret once code matches it and
+	// returns the recovery codes.
+	ConfirmTotp(uuid string, code string) ([]string, error)
+	// DisableTotp turns MFA off given a current code or a recovery code.
+	DisableTotp(uuid string, code string) error
+	Enabled(uuid string) (bool, error)
+	// Challenge starts the second login step for uuid, failing with
+	// errs.KindTooManyRequests once uuid started too many.
+	Challenge(uuid string) (entity.TokenEntity, error)
+	// Login finishes a challenged login with a TOTP or recovery code.
+	Login(mfaToken string, code string, client entity.ClientEntity) (entity.TokenEntity, error)
+}
@@ -0,0 +1,12 @@
+package port
+
+import "github.com/koalachatapp/user/internal/core/entity"
+
+type OutboxRepository interface {
+	// Append writes a message that is not tied to another change.
+	Append(event entity.OutboxEntity) error
+	// Relay hands up to limit unsent messages, oldest first, to publish and
+	// marks those it accepted as sent. It stops at the first failure so
+	// ordering is kept, and returns how many messages were sent.
+	Relay(limit int, publish func(entity.OutboxEntity) error) (int, error)
+}
@@ -0,0 +1,34 @@
+package port
+
+import (
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+type PasskeyRepository interface {
+	Save(passkey entity.PasskeyEntity) error
+	Get(id string) (entity.PasskeyEntity, bool, error)
+	List(uuid string) ([]entity.PasskeyEntity, error)
+	// Used records a successful assertion with its new sign counter.
+	Used(id string, signCount uint32, backupState bool, at time.Time) error
+	// Delete removes passkey id of uuid, reporting whether it existed.
+	Delete(uuid string, id string) (bool, error)
+}
+
+// CeremonyStore keeps WebAuthn ceremony state between its two requests.
+type CeremonyStore interface {
+	Save(id string, state []byte, ttl time.Duration) error
+	// Take returns and deletes the state of ceremony id.
+	Take(id string) ([]byte, bool, error)
+}
+
+type PasskeyService interface {
+	BeginRegistration(uuid string) (entity.CeremonyEntity, error)
+	FinishRegistration(uuid string, result entity.CeremonyResultEntity) (entity.PasskeyEntity, error)
+	// BeginLogin starts a passwordless login with a discoverable credential.
+	BeginLogin() (entity.CeremonyEntity, error)
+	FinishLogin(result entity.CeremonyResultEntity, client entity.ClientEntity) (entity.TokenEntity, error)
+	List(uuid string) ([]entity.PasskeyEntity, error)
+	Revoke(uuid string, id string) error
+}
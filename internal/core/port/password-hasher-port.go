@@ -0,0 +1,14 @@
+package port
+
+// PasswordHasher turns plain passwords into self-describing encoded hashes
+// (PHC string format) and verifies candidates against them, so the algorithm
+// and its parameters can change without invalidating stored credentials.
+type PasswordHasher interface {
+	// Hash encodes password with the current scheme and parameters.
+	Hash(password string) (string, error)
+	// Verify checks password against encoded. uuid is only consulted by the
+	// legacy SHA-512 scheme, whose salt was derived from it. rehash reports
+	// that encoded was produced by an outdated scheme or parameters and should
+	// be replaced by a fresh Hash of the same password.
+	Verify(encoded string, password string, uuid string) (match bool, rehash bool, err error)
+}
@@ -0,0 +1,9 @@
+package port
+
+import "github.com/koalachatapp/user/internal/core/entity"
+
+type PasswordPolicy interface {
+	// Check returns the rules password breaks for user, whose username,
+	// email and name must not make up the password.
+	Check(password string, user entity.UserEntity) []entity.PolicyViolationEntity
+}
@@ -0,0 +1,15 @@
+package port
+
+import "time"
+
+type PasswordResetStore interface {
+	// Create stores the token hash for uuid, replacing its pending token.
+	Create(tokenHash string, uuid string, ttl time.Duration) error
+	// Peek returns the uuid stored under tokenHash.
+	Peek(tokenHash string) (string, bool, error)
+	// Consume returns and deletes the uuid stored under tokenHash.
+	Consume(tokenHash string) (string, bool, error)
+	// Throttle reports whether uuid may be sent another reset mail,
+	// allowing one per interval.
+	Throttle(uuid string, interval time.Duration) (bool, error)
+}
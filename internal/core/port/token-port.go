@@ -0,0 +1,46 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+// TokenIssuer signs access tokens for authenticated users.
+type TokenIssuer interface {
+	// Issue returns a signed token whose subject is the user's uuid and whose
+	// sid claim names the session it was issued for.
+	Issue(subject string, sessionID string) (token string, expiresAt time.Time, err error)
+}
+
+// TokenVerifier validates bearer tokens presented by callers.
+type TokenVerifier interface {
+	// Verify returns the claims of token, or an error when it is malformed,
+	// expired, revoked or not signed by a trusted key.
+	Verify(ctx context.Context, token string) (entity.ClaimsEntity, error)
+}
+
+// SessionStore persists sessions and the hash of each session's current
+// refresh token. Hashes that were rotated out keep resolving to their session
+// until it expires, so a replayed token can be recognised.
+type SessionStore interface {
+	Create(session entity.SessionEntity) error
+	// Lookup resolves a refresh token hash to its session.
+	Lookup(tokenHash string) (entity.SessionEntity, bool, error)
+	// Rotate stores session (carrying the new TokenHash) only if oldHash is
+	// still the current hash, reporting false when it was not.
+	Rotate(session entity.SessionEntity, oldHash string) (bool, error)
+	List(uuid string) ([]entity.SessionEntity, error)
+	Revoke(sessionID string) error
+	RevokeAll(uuid string) error
+}
+
+type SessionService interface {
+	Start(uuid string, client entity.ClientEntity) (entity.TokenEntity, error)
+	Refresh(refreshToken string, client entity.ClientEntity) (entity.TokenEntity, error)
+	Logout(refreshToken string) error
+	List(uuid string) ([]entity.SessionEntity, error)
+	Revoke(uuid string, sessionID string) error
+	RevokeAll(uuid string) error
+}
@@ -3,10 +3,30 @@ package port
 import "github.com/koalachatapp/user/internal/core/entity"
 
 type UserRepository interface {
-	Save(user entity.UserEntity) error
-	Delete(uuid string) (bool, error)
+	// Save, Delete, Update and Patch write event to the outbox in the same
+	// transaction as the user row. Update and Patch reset the verified
+	// state when the email changes. Delete also removes the MFA factor,
+	// recovery codes and passkeys of the user.
+	Save(user entity.UserEntity, event entity.OutboxEntity) error
+	Delete(uuid string, event entity.OutboxEntity) (bool, error)
 	IsExist(username string, email string) (bool, error)
+	// TakenUsernames returns which of usernames belong to a user.
+	TakenUsernames(usernames []string) ([]string, error)
 	IsExistUuid(uuid string) (bool, error)
-	Update(uuid string, user entity.UserEntity) error
-	Patch(uuid string, user entity.UserEntity) error
+	FindByLogin(login string) (entity.UserEntity, bool, error)
+	Get(uuid string) (entity.UserEntity, bool, error)
+	GetByUsername(username string) (entity.UserEntity, bool, error)
+	GetByEmail(email string) (entity.UserEntity, bool, error)
+	// List returns up to query.Limit users in (created_at, uuid) order and
+	// whether more exist in the direction of query.Key.
+	List(query entity.ListQuery) ([]entity.UserEntity, bool, error)
+	Update(uuid string, user entity.UserEntity, event entity.OutboxEntity) error
+	Patch(uuid string, user entity.UserEntity, event entity.OutboxEntity) error
+	UpdatePassword(uuid string, hash string) error
+	// ResetPassword replaces the password hash of uuid and writes event in
+	// the same transaction. It reports whether the user exists.
+	ResetPassword(uuid string, hash string, event entity.OutboxEntity) (bool, error)
+	// MarkVerified verifies uuid if its email is still email and writes
+	// event in the same transaction. It reports whether a row changed.
+	MarkVerified(uuid string, email string, event entity.OutboxEntity) (bool, error)
 }
@@ -1,10 +1,31 @@
 package port
 
-import "github.com/koalachatapp/user/internal/core/entity"
+import (
+	"context"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
 
 type UserService interface {
-	Register(user entity.UserEntity) (string, error)
-	Update(uuid string, user entity.UserEntity) error
-	Patch(uuid string, user entity.UserEntity) error
-	Delete(uuid string) error
+	// Register, Update, Patch, Delete, VerifyEmail and ResetPassword
+	// publish an event naming the actor and correlation id of the
+	// entity.TraceEntity in ctx.
+	Register(ctx context.Context, user entity.UserEntity) (string, error)
+	Update(ctx context.Context, uuid string, user entity.UserEntity) error
+	Patch(ctx context.Context, uuid string, user entity.UserEntity) error
+	Delete(ctx context.Context, uuid string) error
+	Get(uuid string) (entity.UserProfileEntity, error)
+	GetByUsername(username string) (entity.UserProfileEntity, error)
+	GetByEmail(email string) (entity.UserProfileEntity, error)
+	List(query entity.ListQuery) (entity.ListPage, error)
+	Authenticate(ctx context.Context, login string, password string, client entity.ClientEntity) (entity.TokenEntity, error)
+	VerifyEmail(ctx context.Context, token string) error
+	// ResendVerification never reports whether email belongs to an
+	// account waiting for verification.
+	ResendVerification(email string) error
+	// ForgotPassword never reports whether email belongs to an account.
+	ForgotPassword(email string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+	// Availability tells whether username and email are free to register.
+	Availability(username string, email string) (entity.AvailabilityEntity, error)
 }
@@ -0,0 +1,8 @@
+package port
+
+type Validator interface {
+	// Validate checks v against the validate tags of its fields, or only
+	// against those of the named fields when some are given. It returns an
+	// errs.KindValidation error listing every field at fault.
+	Validate(v interface{}, fields ...string) error
+}
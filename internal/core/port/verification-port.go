@@ -0,0 +1,18 @@
+package port
+
+import (
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+type VerificationStore interface {
+	// Create stores the token hash for verification, replacing the pending
+	// token of the same account.
+	Create(tokenHash string, verification entity.VerificationEntity, ttl time.Duration) error
+	// Consume returns and deletes the verification stored under tokenHash.
+	Consume(tokenHash string) (entity.VerificationEntity, bool, error)
+	// Throttle reports whether uuid may request another verification mail,
+	// allowing one per interval.
+	Throttle(uuid string, interval time.Duration) (bool, error)
+}
@@ -0,0 +1,61 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+type WebhookRepository interface {
+	Save(webhook entity.WebhookEntity) error
+	Get(id string) (entity.WebhookEntity, bool, error)
+	List() ([]entity.WebhookEntity, error)
+	// Delete removes webhook id with its deliveries, reporting whether it
+	// existed.
+	Delete(id string) (bool, error)
+	// Enabled returns the webhooks that receive deliveries.
+	Enabled() ([]entity.WebhookEntity, error)
+	// Enqueue stores deliveries, skipping those of an event that is already
+	// queued for the same webhook.
+	Enqueue(deliveries []entity.WebhookDeliveryEntity) error
+	// Claim returns up to limit pending deliveries of enabled webhooks that
+	// are due and hides them from other claimers for lease.
+	Claim(limit int, lease time.Duration) ([]entity.WebhookDeliveryEntity, error)
+	// Attempted stores delivery together with attempt. A failed delivery
+	// counts against its webhook, which is disabled once disableAfter
+	// failed in a row; a succeeded one resets the count. It reports
+	// whether the webhook got disabled.
+	Attempted(delivery entity.WebhookDeliveryEntity, attempt entity.WebhookAttemptEntity, disableAfter int) (bool, error)
+	// Deliveries returns the latest deliveries of webhookID, only those in
+	// status unless it is empty.
+	Deliveries(webhookID string, status string, limit int) ([]entity.WebhookDeliveryEntity, error)
+	// Delivery returns delivery id of webhookID with its attempts.
+	Delivery(webhookID string, id string) (entity.WebhookDeliveryEntity, bool, error)
+	// Replay queues delivery id of webhookID again with fresh attempts.
+	Replay(webhookID string, id string) (entity.WebhookDeliveryEntity, bool, error)
+}
+
+// WebhookSender posts webhook bodies.
+type WebhookSender interface {
+	// Send returns the status of the response; err is set when there was
+	// none.
+	Send(ctx context.Context, url string, headers map[string]string, body []byte) (int, error)
+}
+
+type WebhookService interface {
+	// Publish queues a delivery of a user event to every enabled webhook
+	// subscribed to its type, so the outbox relay can fan out to webhooks.
+	EventPublisher
+	// Run delivers due deliveries until ctx is done.
+	Run(ctx context.Context)
+	Create(request entity.WebhookRequestEntity) (entity.WebhookEntity, error)
+	List() ([]entity.WebhookEntity, error)
+	Get(id string) (entity.WebhookEntity, error)
+	Update(id string, request entity.WebhookRequestEntity) (entity.WebhookEntity, error)
+	Delete(id string) error
+	Deliveries(id string, status string, limit int) ([]entity.WebhookDeliveryEntity, error)
+	Delivery(id string, deliveryID string) (entity.WebhookDeliveryEntity, error)
+	// Replay sends a delivery again, whatever became of it.
+	Replay(id string, deliveryID string) (entity.WebhookDeliveryEntity, error)
+}
@@ -1,19 +1,75 @@
 package port
 
 import (
-	"sync"
+	"context"
+	"fmt"
 
-	"github.com/Shopify/sarama"
+	"github.com/bytedance/sonic"
+	"github.com/koalachatapp/user/internal/core/entity"
 )
 
-// Worker struct has fields for synchronization (WaitGroup and Mutex),
-// task processing (Worker channel), sending data to Kafka (Send2kafka channel),
-// and a Sarama async producer (Prod).
-type Worker struct {
-	Wg         sync.WaitGroup
-	Wg2        sync.WaitGroup
-	Lock       sync.Mutex
-	Worker     chan map[uint8]interface{}
-	Send2kafka chan string
-	Prod       sarama.SyncProducer
+// Job is a unit of background work. Its kind selects the handler that runs
+// it; the job itself must be JSON serializable so it can be persisted for a
+// retry.
+type Job interface {
+	Kind() string
+}
+
+// JobHandler runs the jobs of one kind.
+type JobHandler interface {
+	Kind() string
+	Decode(payload []byte) (Job, error)
+	Run(ctx context.Context, job Job) error
+}
+
+// Worker is a pool running submitted jobs in the background.
+type Worker interface {
+	// Handle registers the handler for its kind of job.
+	Handle(handler JobHandler)
+	// Submit queues job. It blocks while the queue is full and fails once
+	// ctx is done or the pool stopped. A running job submitting with the
+	// context it was given never blocks: when the queue is full its
+	// follow-up is reported failed and goes to the retry store.
+	Submit(ctx context.Context, job Job) error
+	// Resubmit queues a job loaded back from the retry store.
+	Resubmit(ctx context.Context, record entity.JobEntity) error
+	// OnResult registers a callback for every finished job; err is nil on
+	// success.
+	OnResult(func(record entity.JobEntity, err error))
+	// Start runs the pool until ctx is cancelled.
+	Start(ctx context.Context)
+	// Wait blocks until every queued and running job finished.
+	Wait()
+	// Shutdown drains the queue until ctx is done and then stops the pool.
+	// From its start Submit fails for everyone but running jobs, which may
+	// still submit follow-up jobs while it drains. It returns the jobs that did not finish in
+	// time for the caller to persist; one that was still running may
+	// therefore run twice.
+	Shutdown(ctx context.Context) []entity.JobEntity
+}
+
+// HandlerFunc adapts a function over one concrete job type to a JobHandler.
+func HandlerFunc[T Job](run func(ctx context.Context, job T) error) JobHandler {
+	return handlerFunc[T](run)
+}
+
+type handlerFunc[T Job] func(ctx context.Context, job T) error
+
+func (f handlerFunc[T]) Kind() string {
+	var job T
+	return job.Kind()
+}
+
+func (f handlerFunc[T]) Decode(payload []byte) (Job, error) {
+	var job T
+	err := sonic.Unmarshal(payload, &job)
+	return job, err
+}
+
+func (f handlerFunc[T]) Run(ctx context.Context, job Job) error {
+	j, ok := job.(T)
+	if !ok {
+		return fmt.Errorf("%s handler cannot run %T", f.Kind(), job)
+	}
+	return f(ctx, j)
 }
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+const (
+	suggestionCount      = 3
+	suggestionCandidates = 10
+	maxUsernameLength    = 32
+)
+
+// availabilityChecker answers whether usernames and emails are free, from
+// the cache when it can, so typing in the signup form does not reach the
+// database on every key stroke.
+type availabilityChecker struct {
+	cache port.AvailabilityCache
+	ttl   time.Duration
+}
+
+// newAvailabilityChecker keeps answers for AVAILABILITY_CACHE_TTL (default
+// 1m).
+func newAvailabilityChecker(cache port.AvailabilityCache) *availabilityChecker {
+	return &availabilityChecker{
+		cache: cache,
+		ttl:   envDuration("AVAILABILITY_CACHE_TTL", time.Minute),
+	}
+}
+
+// Availability reports whether username and email are free, suggesting
+// free usernames when username is taken. Either may be left empty.
+func (s *userService) Availability(username string, email string) (entity.AvailabilityEntity, error) {
+	user := normalizeUser(entity.UserEntity{Username: username, Email: email})
+	var fields []string
+	if user.Username != "" {
+		fields = append(fields, "Username")
+	}
+	if user.Email != "" {
+		fields = append(fields, "Email")
+	}
+	if len(fields) == 0 {
+		return entity.AvailabilityEntity{}, errs.Validation("username or email is required")
+	}
+	if err := s.validator.Validate(user, fields...); err != nil {
+		return entity.AvailabilityEntity{}, err
+	}
+	var availability entity.AvailabilityEntity
+	if user.Username != "" {
+		taken, err := s.taken("username", user.Username, func() (bool, error) {
+			return s.repository.IsExist(user.Username, "")
+		})
+		if err != nil {
+			return entity.AvailabilityEntity{}, err
+		}
+		availability.Username = &entity.FieldAvailabilityEntity{Value: user.Username, Available: !taken}
+		if taken {
+			availability.Username.Suggestions = s.suggestUsernames(user.Username)
+		}
+	}
+	if user.Email != "" {
+		taken, err := s.taken("email", user.Email, func() (bool, error) {
+			return s.repository.IsExist("", user.Email)
+		})
+		if err != nil {
+			return entity.AvailabilityEntity{}, err
+		}
+		availability.Email = &entity.FieldAvailabilityEntity{Value: user.Email, Available: !taken}
+	}
+	return availability, nil
+}
+
+// taken answers from the cache, falling back to lookup and caching its
+// answer.
+func (s *userService) taken(field string, value string, lookup func() (bool, error)) (bool, error) {
+	taken, found, err := s.availability.cache.Get(field, value)
+	if err != nil {
+		log.Println(err)
+	}
+	if found {
+		return taken, nil
+	}
+	taken, err = lookup()
+	if err != nil {
+		log.Println(err)
+		return false, errs.Unavailable("failed connect to DB", err)
+	}
+	if err := s.availability.cache.Set(field, value, taken, s.availability.ttl); err != nil {
+		log.Println(err)
+	}
+	return taken, nil
+}
+
+// suggestUsernames returns up to suggestionCount free usernames made from
+// base and a random number. It gives up quietly, suggestions being a nicety.
+func (s *userService) suggestUsernames(base string) []string {
+	if len(base) > maxUsernameLength-4 {
+		base = base[:maxUsernameLength-4]
+	}
+	seen := make(map[string]bool, suggestionCandidates)
+	candidates := make([]string, 0, suggestionCandidates)
+	for len(candidates) < suggestionCandidates {
+		var candidate string
+		if len(candidates)%2 == 0 {
+			candidate = base + strconv.Itoa(10+rand.Intn(990))
+		} else {
+			candidate = base + "_" + strconv.Itoa(10+rand.Intn(90))
+		}
+		if !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	taken, err := s.repository.TakenUsernames(candidates)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	for _, username := range taken {
+		seen[username] = false
+	}
+	suggestions := make([]string, 0, suggestionCount)
+	for _, candidate := range candidates {
+		if !seen[candidate] {
+			continue
+		}
+		if err := s.availability.cache.Set("username", candidate, false, s.availability.ttl); err != nil {
+			log.Println(err)
+		}
+		suggestions = append(suggestions, candidate)
+		if len(suggestions) == suggestionCount {
+			break
+		}
+	}
+	return suggestions
+}
+
+// reserve marks the username and email of user taken right away, before
+// the queued write lands, so the form stops offering them.
+func (s *userService) reserve(user entity.UserEntity) {
+	if user.Username != "" {
+		if err := s.availability.cache.Set("username", user.Username, true, s.availability.ttl); err != nil {
+			log.Println(err)
+		}
+	}
+	if user.Email != "" {
+		if err := s.availability.cache.Set("email", user.Email, true, s.availability.ttl); err != nil {
+			log.Println(err)
+		}
+	}
+}
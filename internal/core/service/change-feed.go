@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	usereventv1 "github.com/koalachatapp/user/internal/core/domain/userevent/v1"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+	"google.golang.org/protobuf/proto"
+)
+
+const subscriberBuffer = 64
+
+var changeIDPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+type subscriber struct {
+	uuids   map[string]bool
+	changes chan entity.ChangeEntity
+}
+
+type changeFeed struct {
+	log      port.ChangeLog
+	buffer   int64
+	maxUuids int
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+// NewChangeFeed streams user events to the clients following the users
+// they are about. Any signed-in user may follow anyone, so personal data is
+// cleared from the events even when EVENT_INCLUDE_PII keeps it on the
+// topic. The log keeps the last CHANGE_FEED_BUFFER changes (default 1000)
+// for clients to resume from, and a client follows at most
+// CHANGE_FEED_MAX_UUIDS users (default 100).
+func NewChangeFeed(log port.ChangeLog) port.ChangeFeed {
+	return &changeFeed{
+		log:         log,
+		buffer:      int64(envPositive("CHANGE_FEED_BUFFER", 1000)),
+		maxUuids:    envPositive("CHANGE_FEED_MAX_UUIDS", 100),
+		subscribers: map[*subscriber]bool{},
+	}
+}
+
+// Publish appends event to the log when it is a user event. The relay may
+// publish an event twice; subscribers can tell by the CloudEvents id.
+func (f *changeFeed) Publish(ctx context.Context, event entity.OutboxEntity) error {
+	if len(event.Headers) == 0 {
+		return nil
+	}
+	var headers map[string]string
+	if err := sonic.Unmarshal(event.Headers, &headers); err != nil {
+		return err
+	}
+	if headers["ce_subject"] == "" || !strings.HasPrefix(headers["ce_type"], "app.koalachat.user.") {
+		return nil
+	}
+	payload, err := redactedPayload(event.Payload)
+	if err != nil {
+		return err
+	}
+	event.Payload = payload
+	body, err := structuredEvent(headers, event)
+	if err != nil {
+		return err
+	}
+	_, err = f.log.Append(entity.ChangeEntity{
+		Subject: headers["ce_subject"],
+		Type:    headers["ce_type"],
+		Event:   body,
+	}, f.buffer)
+	return err
+}
+
+// redactedPayload is the user event payload without personal data.
+func redactedPayload(payload []byte) ([]byte, error) {
+	var data usereventv1.UserEvent
+	if err := proto.Unmarshal(payload, &data); err != nil {
+		return nil, err
+	}
+	redact(data.ProtoReflect())
+	return proto.Marshal(&data)
+}
+
+func (f *changeFeed) Close() error {
+	return nil
+}
+
+func (f *changeFeed) Run(ctx context.Context) {
+	last, err := f.log.Last()
+	for err != nil {
+		log.Println("change feed:", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+		last, err = f.log.Last()
+	}
+	for ctx.Err() == nil {
+		changes, err := f.log.Tail(ctx, last, 5*time.Second)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println("change feed:", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		for _, change := range changes {
+			f.broadcast(change)
+			last = change.ID
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for s := range f.subscribers {
+		delete(f.subscribers, s)
+		close(s.changes)
+	}
+}
+
+// broadcast hands change to its subscribers, dropping those too far
+// behind to take it.
+func (f *changeFeed) broadcast(change entity.ChangeEntity) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for s := range f.subscribers {
+		if !s.uuids[change.Subject] {
+			continue
+		}
+		select {
+		case s.changes <- change:
+		default:
+			delete(f.subscribers, s)
+			close(s.changes)
+		}
+	}
+}
+
+func (f *changeFeed) Subscribe(ctx context.Context, uuids []string, lastEventID string) (<-chan entity.ChangeEntity, error) {
+	s := &subscriber{
+		uuids:   map[string]bool{},
+		changes: make(chan entity.ChangeEntity, subscriberBuffer),
+	}
+	for _, id := range uuids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, errs.Invalid("uuids", "uuid", id+" is not a uuid")
+		}
+		s.uuids[id] = true
+	}
+	if len(s.uuids) == 0 {
+		return nil, errs.Invalid("uuids", "required", "uuids cannot be empty")
+	}
+	if len(s.uuids) > f.maxUuids {
+		return nil, errs.Invalid("uuids", "max", "at most "+strconv.Itoa(f.maxUuids)+" uuids can be followed")
+	}
+	if lastEventID != "" && !changeIDPattern.MatchString(lastEventID) {
+		return nil, errs.Invalid("last_event_id", "format", "invalid last event id")
+	}
+
+	// subscribe before reading the log, so nothing falls in between; what
+	// arrives both ways is skipped by id
+	f.mu.Lock()
+	f.subscribers[s] = true
+	f.mu.Unlock()
+	var replay []entity.ChangeEntity
+	found := true
+	if lastEventID != "" {
+		var err error
+		replay, found, err = f.log.Since(lastEventID, f.buffer)
+		if err != nil {
+			f.unsubscribe(s)
+			log.Println(err)
+			return nil, errs.Unavailable("failed to read change log", err)
+		}
+	}
+
+	out := make(chan entity.ChangeEntity, subscriberBuffer)
+	go func() {
+		defer close(out)
+		defer f.unsubscribe(s)
+		send := func(change entity.ChangeEntity) bool {
+			select {
+			case out <- change:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !found && !send(entity.ChangeEntity{Reset: true}) {
+			return
+		}
+		cursor := lastEventID
+		for _, change := range replay {
+			cursor = change.ID
+			if s.uuids[change.Subject] && !send(change) {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change, ok := <-s.changes:
+				if !ok {
+					return
+				}
+				if cursor != "" && compareChangeIDs(change.ID, cursor) <= 0 {
+					continue
+				}
+				if !send(change) {
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (f *changeFeed) unsubscribe(s *subscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subscribers[s] {
+		delete(f.subscribers, s)
+		close(s.changes)
+	}
+}
+
+// compareChangeIDs orders two change ids of the form <millis>-<seq>.
+func compareChangeIDs(a string, b string) int {
+	am, as := splitChangeID(a)
+	bm, bs := splitChangeID(b)
+	switch {
+	case am != bm:
+		if am < bm {
+			return -1
+		}
+		return 1
+	case as != bs:
+		if as < bs {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitChangeID(id string) (uint64, uint64) {
+	millis, seq, _ := strings.Cut(id, "-")
+	m, _ := strconv.ParseUint(millis, 10, 64)
+	s, _ := strconv.ParseUint(seq, 10, 64)
+	return m, s
+}
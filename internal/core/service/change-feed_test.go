@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// changeList is a change log keeping what is appended.
+type changeList struct {
+	port.ChangeLog
+	changes []entity.ChangeEntity
+}
+
+func (c *changeList) Append(change entity.ChangeEntity, max int64) (string, error) {
+	c.changes = append(c.changes, change)
+	return "1-0", nil
+}
+
+func TestChangeFeedRedacts(t *testing.T) {
+	created := time.Date(2024, 5, 17, 9, 30, 0, 0, time.UTC)
+	user := entity.UserEntity{Uuid: "u1", Username: "koala", Name: "Kö Ala", Email: "koala@example.com", Password: "$argon2id$secret", Verified: true, CreatedAt: created}
+	// the topic carries personal data, the feed must not
+	s := &userService{events: &userEvents{source: "/test", topic: "UsersearchTopic", includePII: true}}
+
+	for _, method := range []string{"register", "patch", "verify", "delete"} {
+		t.Run(method, func(t *testing.T) {
+			log := &changeList{}
+			feed := NewChangeFeed(log)
+			event, err := s.newUserEvent(context.Background(), method, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := feed.Publish(context.Background(), event); err != nil {
+				t.Fatal(err)
+			}
+			if len(log.changes) != 1 {
+				t.Fatalf("appended %d changes, want 1", len(log.changes))
+			}
+			change := log.changes[0].Event
+			var body struct {
+				Type    string `json:"type"`
+				Subject string `json:"subject"`
+				Data    struct {
+					User map[string]interface{} `json:"user"`
+				} `json:"data"`
+			}
+			if err := sonic.Unmarshal(change, &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Type != userEventTypes[method] || body.Subject != "u1" {
+				t.Errorf("type %q subject %q, want %q u1", body.Type, body.Subject, userEventTypes[method])
+			}
+			if body.Data.User["uuid"] != "u1" || body.Data.User["username"] != "koala" {
+				t.Errorf("user %v lacks the public fields", body.Data.User)
+			}
+			if _, ok := body.Data.User["email"]; ok {
+				t.Errorf("user %v carries the email", body.Data.User)
+			}
+			for _, secret := range []string{"koala@example.com", "Kö Ala", "$argon2id$"} {
+				if bytes.Contains(change, []byte(secret)) {
+					t.Errorf("change carries %q", secret)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeLimit(t *testing.T) {
+	t.Setenv("CHANGE_FEED_MAX_UUIDS", "2")
+	feed := NewChangeFeed(&changeList{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := feed.Subscribe(ctx, []string{uuid.NewString(), uuid.NewString()}, ""); err != nil {
+		t.Fatalf("following 2 users: %v", err)
+	}
+	_, err := feed.Subscribe(ctx, []string{uuid.NewString(), uuid.NewString(), uuid.NewString()}, "")
+	if !errors.Is(err, errs.ErrValidation) {
+		t.Errorf("following 3 users: err = %v, want %v", err, errs.ErrValidation)
+	}
+}
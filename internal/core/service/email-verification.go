@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+var errInvalidVerificationToken = errs.Invalid("token", "token", "invalid verification token")
+
+// emailVerifier issues the single-use links that prove an account owns its
+// email address.
+type emailVerifier struct {
+	store    port.VerificationStore
+	mailer   port.Mailer
+	link     string
+	ttl      time.Duration
+	interval time.Duration
+}
+
+// newEmailVerifier reads EMAIL_VERIFICATION_URL, the page the link opens
+// with ?token=, EMAIL_VERIFICATION_TTL (default 24h) and
+// EMAIL_RESEND_INTERVAL, the minimum time between two resends (default 1m).
+func newEmailVerifier(store port.VerificationStore, mailer port.Mailer) *emailVerifier {
+	link := os.Getenv("EMAIL_VERIFICATION_URL")
+	if link == "" {
+		link = "http://localhost:3000/verify-email"
+	}
+	ttl, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL"))
+	if err != nil {
+		ttl = 24 * time.Hour
+	}
+	interval, err := time.ParseDuration(os.Getenv("EMAIL_RESEND_INTERVAL"))
+	if err != nil {
+		interval = time.Minute
+	}
+	return &emailVerifier{
+		store:    store,
+		mailer:   mailer,
+		link:     link,
+		ttl:      ttl,
+		interval: interval,
+	}
+}
+
+// send mails a new verification link, which invalidates any earlier one.
+func (v *emailVerifier) send(ctx context.Context, job entity.SendVerificationJob) error {
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		return err
+	}
+	if err := v.store.Create(hash, entity.VerificationEntity{Uuid: job.Uuid, Email: job.Email}, v.ttl); err != nil {
+		return err
+	}
+	return v.mailer.Send(ctx, entity.MailEntity{
+		To:      job.Email,
+		Subject: "Verify your email address",
+		Body: "Open the link below to verify your email address:\n\n" +
+			v.link + "?token=" + url.QueryEscape(token) + "\n\n" +
+			"The link expires in " + v.ttl.String() + ". If you did not sign up, ignore this mail.\n",
+	})
+}
+
+func (s *userService) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return errInvalidVerificationToken
+	}
+	verification, found, err := s.verifier.store.Consume(hashRefreshToken(token))
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to load verification", err)
+	}
+	if !found {
+		return errInvalidVerificationToken
+	}
+	event, err := s.newUserEvent(ctx, "verify", entity.UserEntity{Uuid: verification.Uuid, Email: verification.Email, Verified: true})
+	if err != nil {
+		return err
+	}
+	verified, err := s.repository.MarkVerified(verification.Uuid, verification.Email, event)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	if !verified {
+		// the email changed since the link was sent
+		return errInvalidVerificationToken
+	}
+	if err := s.invalidate(verification.Uuid); err != nil {
+		log.Println(err)
+	}
+	if err := s.worker.Submit(context.TODO(), entity.PublishEventJob{}); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// ResendVerification queues a new link without looking the account up, so
+// the answer and its timing are the same whether email is registered,
+// verified or not.
+func (s *userService) ResendVerification(email string) error {
+	email = normalizeEmail(email)
+	if err := s.validator.Validate(entity.ResendVerificationEntity{Email: email}); err != nil {
+		return nil
+	}
+	if err := s.worker.Submit(context.TODO(), entity.ResendVerificationJob{Email: email}); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+func (s *userService) resendVerification(ctx context.Context, job entity.ResendVerificationJob) error {
+	user, found, err := s.repository.GetByEmail(job.Email)
+	if err != nil {
+		return err
+	}
+	if !found || user.Verified {
+		return nil
+	}
+	allowed, err := s.verifier.store.Throttle(user.Uuid, s.verifier.interval)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		log.Printf("verification resend for %s throttled\n", user.Uuid)
+		return nil
+	}
+	return s.verifier.send(ctx, entity.SendVerificationJob{Uuid: user.Uuid, Email: user.Email})
+}
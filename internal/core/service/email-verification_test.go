@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// emailList is a user repository finding users by email.
+type emailList struct {
+	port.UserRepository
+	users []entity.UserEntity
+}
+
+func (e *emailList) GetByEmail(email string) (entity.UserEntity, bool, error) {
+	for _, user := range e.users {
+		if user.Email == email {
+			return user, true, nil
+		}
+	}
+	return entity.UserEntity{}, false, nil
+}
+
+// verificationMap keeps pending verifications and lets each account have
+// one mail.
+type verificationMap struct {
+	port.VerificationStore
+	pending   map[string]entity.VerificationEntity
+	throttled map[string]bool
+}
+
+func (v *verificationMap) Create(tokenHash string, verification entity.VerificationEntity, ttl time.Duration) error {
+	v.pending[tokenHash] = verification
+	return nil
+}
+
+func (v *verificationMap) Throttle(uuid string, interval time.Duration) (bool, error) {
+	if v.throttled[uuid] {
+		return false, nil
+	}
+	v.throttled[uuid] = true
+	return true, nil
+}
+
+// mailBox keeps the mails sent.
+type mailBox struct {
+	mails []entity.MailEntity
+}
+
+func (m *mailBox) Send(ctx context.Context, mail entity.MailEntity) error {
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+func TestResendVerification(t *testing.T) {
+	users := &emailList{users: []entity.UserEntity{
+		{Uuid: "u1", Email: "new@example.com"},
+		{Uuid: "u2", Email: "old@example.com", Verified: true},
+	}}
+	tests := []struct {
+		name  string
+		email string
+		// the same address twice in a row
+		mails []int
+	}{
+		{"unverified", "new@example.com", []int{1, 1}},
+		{"verified", "old@example.com", []int{0, 0}},
+		{"unknown", "nobody@example.com", []int{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mails := &mailBox{}
+			store := &verificationMap{pending: map[string]entity.VerificationEntity{}, throttled: map[string]bool{}}
+			s := &userService{repository: users, verifier: newEmailVerifier(store, mails)}
+			for i, want := range tt.mails {
+				if err := s.resendVerification(context.Background(), entity.ResendVerificationJob{Email: tt.email}); err != nil {
+					t.Fatal(err)
+				}
+				if len(mails.mails) != want {
+					t.Errorf("request %d sent %d mails, want %d", i+1, len(mails.mails), want)
+				}
+			}
+			for _, verification := range store.pending {
+				if verification.Uuid != "u1" || verification.Email != "new@example.com" {
+					t.Errorf("pending verification %+v", verification)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type jobService struct {
+	repository  port.JobRepository
+	worker      port.Worker
+	box         port.SecretBox
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	lease       time.Duration
+}
+
+// NewJobService creates the retry queue for the jobs of worker. A job gets
+// JOB_MAX_ATTEMPTS attempts (default 8) with jittered exponential backoff
+// from 2s up to 10m before it is dead-lettered. Stored payloads are sealed
+// with box.
+func NewJobService(repository port.JobRepository, worker port.Worker, box port.SecretBox) port.JobService {
+	maxAttempts, err := strconv.Atoi(os.Getenv("JOB_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	jobs := &jobService{
+		repository:  repository,
+		worker:      worker,
+		box:         box,
+		maxAttempts: maxAttempts,
+		baseDelay:   2 * time.Second,
+		maxDelay:    10 * time.Minute,
+		lease:       5 * time.Minute,
+	}
+	worker.OnResult(func(job entity.JobEntity, err error) {
+		if err != nil {
+			jobs.Failed(job, err)
+			return
+		}
+		jobs.Succeeded(job)
+	})
+	return jobs
+}
+
+func (j *jobService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		jobs, err := j.repository.Claim(50, j.lease)
+		if err != nil {
+			log.Println("retry queue:", err)
+			continue
+		}
+		if len(jobs) > 0 {
+			log.Printf("rerunning %d pending task..\n", len(jobs))
+		}
+		for _, job := range jobs {
+			opened, err := j.open(job)
+			if err != nil {
+				j.Failed(job, err)
+				continue
+			}
+			if err := j.worker.Resubmit(ctx, opened); err != nil {
+				j.Failed(job, err)
+			}
+		}
+	}
+}
+
+func (j *jobService) Failed(job entity.JobEntity, err error) {
+	job.Attempts++
+	job.LastError = err.Error()
+	job, sealErr := j.seal(job)
+	if sealErr != nil {
+		log.Println(sealErr)
+		return
+	}
+	if job.Attempts >= j.maxAttempts {
+		log.Printf("job %s (%s) dead-lettered after %d attempts: %v\n", job.ID, job.Kind, job.Attempts, err)
+		if err := j.repository.Bury(entity.DeadJobEntity{JobEntity: job, DeadAt: time.Now()}); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	job.NextRunAt = time.Now().Add(j.backoff(job.Attempts))
+	log.Printf("job %s (%s) failed, attempt %d, retry at %s: %v\n", job.ID, job.Kind, job.Attempts, job.NextRunAt.Format(time.RFC3339), err)
+	if err := j.repository.Schedule(job); err != nil {
+		log.Println(err)
+	}
+}
+
+func (j *jobService) Succeeded(job entity.JobEntity) {
+	if !job.Stored {
+		return
+	}
+	if err := j.repository.Complete(job.ID); err != nil {
+		log.Println(err)
+	}
+}
+
+func (j *jobService) Persist(jobs []entity.JobEntity) error {
+	var failed int
+	for _, job := range jobs {
+		job.NextRunAt = time.Now()
+		job, err := j.seal(job)
+		if err != nil {
+			log.Println(err)
+			failed++
+			continue
+		}
+		if err := j.repository.Schedule(job); err != nil {
+			log.Println(err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to persist %d of %d jobs", failed, len(jobs))
+	}
+	return nil
+}
+
+func (j *jobService) DeadLetters(limit int) ([]entity.DeadJobEntity, error) {
+	if limit <= 0 || limit > maxListLimit {
+		limit = maxListLimit
+	}
+	jobs, err := j.repository.DeadLetters(limit)
+	if err != nil {
+		log.Println(err)
+		return nil, errs.Unavailable("failed connect to DB", err)
+	}
+	return jobs, nil
+}
+
+func (j *jobService) Redrive(id string) error {
+	job, found, err := j.repository.Unbury(id)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return errs.NotFound("job not found")
+	}
+	job.Attempts = 0
+	job.LastError = ""
+	job.NextRunAt = time.Now()
+	if err := j.repository.Schedule(job); err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	return nil
+}
+
+// seal encrypts the payload of job before it is stored. A job loaded back
+// sealed, as a redriven one, is stored as it is.
+func (j *jobService) seal(job entity.JobEntity) (entity.JobEntity, error) {
+	if job.Sealed {
+		return job, nil
+	}
+	payload, err := j.box.Seal(job.Payload)
+	if err != nil {
+		return job, err
+	}
+	job.Payload = payload
+	job.Sealed = true
+	return job, nil
+}
+
+// open decrypts the payload of a stored job for the worker.
+func (j *jobService) open(job entity.JobEntity) (entity.JobEntity, error) {
+	if !job.Sealed {
+		return job, nil
+	}
+	payload, err := j.box.Open(job.Payload)
+	if err != nil {
+		return job, err
+	}
+	job.Payload = payload
+	job.Sealed = false
+	return job, nil
+}
+
+func (j *jobService) backoff(attempt int) time.Duration {
+	return backoff(attempt, j.baseDelay, j.maxDelay)
+}
+
+// backoff returns the delay before the given attempt: exponential in the
+// attempt number from base, capped at max, with the upper half jittered.
+func backoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	d := max
+	if attempt < 30 {
+		if exp := base << uint(attempt-1); exp < d {
+			d = exp
+		}
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"github.com/koalachatapp/user/internal/encryption"
+)
+
+// jobStore keeps what the job service stores.
+type jobStore struct {
+	port.JobRepository
+	scheduled []entity.JobEntity
+	buried    []entity.DeadJobEntity
+}
+
+func (s *jobStore) Schedule(job entity.JobEntity) error {
+	s.scheduled = append(s.scheduled, job)
+	return nil
+}
+
+func (s *jobStore) Bury(job entity.DeadJobEntity) error {
+	s.buried = append(s.buried, job)
+	return nil
+}
+
+// idleWorker is a worker the test never runs jobs on.
+type idleWorker struct {
+	port.Worker
+}
+
+func (idleWorker) OnResult(func(entity.JobEntity, error)) {}
+
+func TestJobPayloadSealed(t *testing.T) {
+	box, err := encryption.NewAesGcmBox(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &jobStore{}
+	jobs := NewJobService(store, idleWorker{}, box).(*jobService)
+	jobs.maxAttempts = 2
+
+	hash := "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA"
+	payload, _ := sonic.Marshal(entity.UpdatePasswordJob{Uuid: "u1", Hash: hash})
+	job := entity.JobEntity{ID: "j1", Kind: entity.JobUpdatePassword, Payload: payload}
+
+	jobs.Failed(job, errors.New("db down"))
+	if len(store.scheduled) != 1 {
+		t.Fatalf("scheduled %d jobs, want 1", len(store.scheduled))
+	}
+	stored := store.scheduled[0]
+	if !stored.Sealed || bytes.Contains(stored.Payload, []byte(hash)) {
+		t.Fatal("retry payload stored in plaintext")
+	}
+	if stored.LastError != "db down" {
+		t.Errorf("last error = %q", stored.LastError)
+	}
+
+	opened, err := jobs.open(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Sealed || !bytes.Equal(opened.Payload, payload) {
+		t.Errorf("opened payload = %s, want %s", opened.Payload, payload)
+	}
+
+	// a job failing again while still sealed is not sealed twice
+	jobs.Failed(stored, errors.New("db down"))
+	if len(store.buried) != 1 {
+		t.Fatalf("buried %d jobs, want 1", len(store.buried))
+	}
+	if again, err := jobs.open(store.buried[0].JobEntity); err != nil || !bytes.Equal(again.Payload, payload) {
+		t.Errorf("buried payload does not open: %v", err)
+	}
+
+	b, _ := sonic.Marshal(store.buried[0])
+	if bytes.Contains(b, []byte("payload")) {
+		t.Errorf("dead job lists its payload: %s", b)
+	}
+}
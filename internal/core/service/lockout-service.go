@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	securityeventv1 "github.com/koalachatapp/user/internal/core/domain/securityevent/v1"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type lockoutService struct {
+	store      port.LockoutStore
+	outbox     port.OutboxRepository
+	worker     port.Worker
+	events     *userEvents
+	maxAccount int64
+	maxIP      int64
+	free       int64
+	delay      time.Duration
+	window     time.Duration
+	lockout    time.Duration
+}
+
+// NewLockoutService throttles failed logins per account and per IP. The
+// first 3 failures within LOGIN_FAILURE_WINDOW (default 15m) are free, each
+// further one delays the next try by twice as long starting at 1s, and
+// LOGIN_MAX_FAILURES (default 10) per account or LOGIN_IP_MAX_FAILURES
+// (default 50) per IP lock logins for LOGIN_LOCKOUT (default 15m).
+func NewLockoutService(store port.LockoutStore, outbox port.OutboxRepository, worker port.Worker) port.LockoutService {
+	return &lockoutService{
+		store:      store,
+		outbox:     outbox,
+		worker:     worker,
+		events:     newUserEvents(),
+		maxAccount: int64(envPositive("LOGIN_MAX_FAILURES", 10)),
+		maxIP:      int64(envPositive("LOGIN_IP_MAX_FAILURES", 50)),
+		free:       3,
+		delay:      time.Second,
+		window:     envDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		lockout:    envDuration("LOGIN_LOCKOUT", 15*time.Minute),
+	}
+}
+
+func accountKey(uuid string, login string) string {
+	if uuid != "" {
+		return "user:" + uuid
+	}
+	return "login:" + strings.ToLower(login)
+}
+
+func ipKey(ip string) string { return "ip:" + ip }
+
+func (l *lockoutService) Check(uuid string, login string, ip string) error {
+	d, err := l.store.Locked(accountKey(uuid, login), ipKey(ip))
+	if err != nil {
+		// fail open, the global rate limit still applies
+		log.Println(err)
+		return nil
+	}
+	if d > 0 {
+		return errs.TooManyRequests("too many failed logins, retry in "+strconv.Itoa(int(d.Seconds()+1))+"s", d)
+	}
+	return nil
+}
+
+func (l *lockoutService) Failed(uuid string, login string, ip string) {
+	l.fail(accountKey(uuid, login), l.maxAccount, entity.SecurityEventEntity{Uuid: uuid})
+	l.fail(ipKey(ip), l.maxIP, entity.SecurityEventEntity{IP: ip})
+}
+
+func (l *lockoutService) fail(key string, max int64, event entity.SecurityEventEntity) {
+	failures, err := l.store.Fail(key, l.window)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	d := l.penalty(failures, max)
+	if d == 0 {
+		return
+	}
+	if err := l.store.Lock(key, d); err != nil {
+		log.Println(err)
+		return
+	}
+	if failures >= max {
+		log.Printf("logins for %s locked for %s after %d failures\n", key, d, failures)
+		event.Method = "lockout"
+		event.Failures = failures
+		event.Until = time.Now().Add(d)
+		l.publish(event)
+	}
+}
+
+// penalty is how long the next login must wait after failures.
+func (l *lockoutService) penalty(failures int64, max int64) time.Duration {
+	if failures >= max {
+		return l.lockout
+	}
+	if failures < l.free {
+		return 0
+	}
+	shift := failures - l.free
+	if shift > 20 {
+		return l.lockout
+	}
+	d := l.delay << uint(shift)
+	if d > l.lockout {
+		d = l.lockout
+	}
+	return d
+}
+
+func (l *lockoutService) Succeeded(uuid string) {
+	if _, err := l.store.Clear(accountKey(uuid, "")); err != nil {
+		log.Println(err)
+	}
+}
+
+func (l *lockoutService) UnlockUser(uuid string, actor string) error {
+	return l.unlock(accountKey(uuid, ""), entity.SecurityEventEntity{Uuid: uuid, Actor: actor})
+}
+
+func (l *lockoutService) UnlockIP(ip string, actor string) error {
+	return l.unlock(ipKey(ip), entity.SecurityEventEntity{IP: ip, Actor: actor})
+}
+
+func (l *lockoutService) unlock(key string, event entity.SecurityEventEntity) error {
+	locked, err := l.store.Clear(key)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to clear lockout", err)
+	}
+	if !locked {
+		return errs.NotFound("not locked")
+	}
+	event.Method = "unlock"
+	l.publish(event)
+	return nil
+}
+
+// publish writes event to the outbox as a CloudEvent about the locked
+// account, or the IP when there is none.
+func (l *lockoutService) publish(event entity.SecurityEventEntity) {
+	data := &securityeventv1.LoginLock{
+		Uuid:     event.Uuid,
+		Ip:       event.IP,
+		Failures: event.Failures,
+	}
+	if !event.Until.IsZero() {
+		data.Until = timestamppb.New(event.Until)
+	}
+	subject := event.Uuid
+	if subject == "" {
+		subject = event.IP
+	}
+	outbox, err := l.events.cloudEvent(entity.TraceEntity{Actor: event.Actor}, securityEventTypes[event.Method], subject, securityEventSchema, data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := l.outbox.Append(outbox); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := l.worker.Submit(context.TODO(), entity.PublishEventJob{}); err != nil {
+		log.Println(err)
+	}
+}
+
+func envPositive(key string, def int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || i <= 0 {
+		return def
+	}
+	return i
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
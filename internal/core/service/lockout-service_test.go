@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	securityeventv1 "github.com/koalachatapp/user/internal/core/domain/securityevent/v1"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"google.golang.org/protobuf/proto"
+)
+
+// outboxStore keeps the events appended to the outbox.
+type outboxStore struct {
+	port.OutboxRepository
+	events []entity.OutboxEntity
+}
+
+func (o *outboxStore) Append(event entity.OutboxEntity) error {
+	o.events = append(o.events, event)
+	return nil
+}
+
+// queueWorker accepts every job without running it.
+type queueWorker struct {
+	port.Worker
+}
+
+func (queueWorker) Submit(context.Context, port.Job) error { return nil }
+
+func TestLockoutEvents(t *testing.T) {
+	until := time.Date(2024, 5, 17, 9, 45, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		routes  string
+		event   entity.SecurityEventEntity
+		typ     string
+		topic   string
+		subject string
+		actor   string
+	}{
+		{
+			name:    "account locked",
+			event:   entity.SecurityEventEntity{Method: "lockout", Uuid: "u1", Failures: 10, Until: until},
+			typ:     "app.koalachat.security.login_locked.v1",
+			topic:   "SecurityTopic",
+			subject: "u1",
+		},
+		{
+			name:    "ip unlocked",
+			event:   entity.SecurityEventEntity{Method: "unlock", IP: "192.0.2.7", Actor: "admin-1"},
+			typ:     "app.koalachat.security.login_unlocked.v1",
+			topic:   "SecurityTopic",
+			subject: "192.0.2.7",
+			actor:   "admin-1",
+		},
+		{
+			name:    "routed",
+			routes:  "app.koalachat.security.login_locked.v1=LockoutTopic",
+			event:   entity.SecurityEventEntity{Method: "lockout", IP: "192.0.2.7", Failures: 50, Until: until},
+			typ:     "app.koalachat.security.login_locked.v1",
+			topic:   "LockoutTopic",
+			subject: "192.0.2.7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EVENT_ROUTES", tt.routes)
+			outbox := &outboxStore{}
+			l := NewLockoutService(nil, outbox, queueWorker{}).(*lockoutService)
+			l.publish(tt.event)
+			if len(outbox.events) != 1 {
+				t.Fatalf("appended %d events, want 1", len(outbox.events))
+			}
+			event := outbox.events[0]
+			if event.Topic != tt.topic || event.Key != tt.subject {
+				t.Errorf("topic %q key %q, want %q %q", event.Topic, event.Key, tt.topic, tt.subject)
+			}
+			var headers map[string]string
+			if err := sonic.Unmarshal(event.Headers, &headers); err != nil {
+				t.Fatal(err)
+			}
+			if headers["ce_type"] != tt.typ || headers["ce_subject"] != tt.subject || headers["ce_actor"] != tt.actor {
+				t.Errorf("headers %v, want type %q subject %q actor %q", headers, tt.typ, tt.subject, tt.actor)
+			}
+			if headers["ce_dataschema"] != securityEventSchema || headers["ce_id"] == "" {
+				t.Errorf("headers %v lack the schema or id", headers)
+			}
+			var data securityeventv1.LoginLock
+			if err := proto.Unmarshal(event.Payload, &data); err != nil {
+				t.Fatal(err)
+			}
+			if data.Uuid != tt.event.Uuid || data.Ip != tt.event.IP || data.Failures != tt.event.Failures {
+				t.Errorf("data %v, want %+v", &data, tt.event)
+			}
+			if !tt.event.Until.IsZero() && !data.Until.AsTime().Equal(until) {
+				t.Errorf("until %v, want %v", data.Until.AsTime(), until)
+			}
+		})
+	}
+}
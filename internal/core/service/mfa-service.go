@@ -0,0 +1,307 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"image/png"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+var (
+	errInvalidMfaCode  = errs.Invalid("code", "code", "invalid code")
+	errMfaLoginFailed  = errs.Unauthorized("invalid code")
+	errInvalidMfaToken = errs.Unauthorized("invalid mfa token")
+	errMfaEnabled      = errs.FailedPrecondition("mfa already enabled")
+)
+
+const (
+	totpPeriod        = 30
+	recoveryCodeCount = 10
+)
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+type mfaService struct {
+	repository    port.MfaRepository
+	users         port.UserRepository
+	challenges    port.MfaChallengeStore
+	box           port.SecretBox
+	sessions      port.SessionService
+	lockout       port.LockoutService
+	issuer        string
+	skew          int
+	challengeTTL  time.Duration
+	maxAttempts   int
+	maxChallenges int
+}
+
+// NewMfaService creates the TOTP second factor. Codes are accepted
+// TOTP_SKEW steps (default 1) either side of the current one; a login
+// challenge lasts MFA_CHALLENGE_TTL (default 5m) and allows 5 tries, and an
+// account gets MFA_MAX_CHALLENGES (default 5) challenges per TTL. Wrong
+// codes count as failed logins with lockout.
+func NewMfaService(repository port.MfaRepository, users port.UserRepository, challenges port.MfaChallengeStore, box port.SecretBox, sessions port.SessionService, lockout port.LockoutService) port.MfaService {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Koala Chat"
+	}
+	skew, err := strconv.Atoi(os.Getenv("TOTP_SKEW"))
+	if err != nil || skew < 0 {
+		skew = 1
+	}
+	ttl, err := time.ParseDuration(os.Getenv("MFA_CHALLENGE_TTL"))
+	if err != nil {
+		ttl = 5 * time.Minute
+	}
+	return &mfaService{
+		repository:    repository,
+		users:         users,
+		challenges:    challenges,
+		box:           box,
+		sessions:      sessions,
+		lockout:       lockout,
+		issuer:        issuer,
+		skew:          skew,
+		challengeTTL:  ttl,
+		maxAttempts:   5,
+		maxChallenges: envPositive("MFA_MAX_CHALLENGES", 5),
+	}
+}
+
+func (m *mfaService) SetupTotp(uuid string) (entity.TotpSetupEntity, error) {
+	user, found, err := m.users.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return entity.TotpSetupEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return entity.TotpSetupEntity{}, errs.NotFound("uuid not found")
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      m.issuer,
+		AccountName: user.Username,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		log.Println(err)
+		return entity.TotpSetupEntity{}, errs.Internal("failed to create secret", err)
+	}
+	secret, err := m.box.Seal([]byte(key.Secret()))
+	if err != nil {
+		log.Println(err)
+		return entity.TotpSetupEntity{}, errs.Internal("failed to create secret", err)
+	}
+	saved, err := m.repository.SavePending(entity.MfaEntity{Uuid: uuid, Secret: secret, CreatedAt: time.Now()})
+	if err != nil {
+		log.Println(err)
+		return entity.TotpSetupEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !saved {
+		return entity.TotpSetupEntity{}, errMfaEnabled
+	}
+	var qr bytes.Buffer
+	img, err := key.Image(256, 256)
+	if err == nil {
+		err = png.Encode(&qr, img)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+	return entity.TotpSetupEntity{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: qr.Bytes(),
+	}, nil
+}
+
+func (m *mfaService) ConfirmTotp(uuid string, code string) ([]string, error) {
+	mfa, found, err := m.repository.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return nil, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return nil, errs.NotFound("mfa not set up")
+	}
+	if mfa.Enabled {
+		return nil, errMfaEnabled
+	}
+	ok, err := m.checkTotp(mfa, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errInvalidMfaCode
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := recoveryEncoding.EncodeToString(b)[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	if err := m.repository.Enable(uuid, hashes); err != nil {
+		log.Println(err)
+		return nil, errs.Unavailable("failed connect to DB", err)
+	}
+	return codes, nil
+}
+
+func (m *mfaService) DisableTotp(uuid string, code string) error {
+	mfa, found, err := m.repository.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	if !found || !mfa.Enabled {
+		return errs.NotFound("mfa not enabled")
+	}
+	ok, err := m.checkCode(mfa, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalidMfaCode
+	}
+	if err := m.repository.Delete(uuid); err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	return nil
+}
+
+func (m *mfaService) Enabled(uuid string) (bool, error) {
+	mfa, found, err := m.repository.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return false, errs.Unavailable("failed connect to DB", err)
+	}
+	return found && mfa.Enabled, nil
+}
+
+func (m *mfaService) Challenge(uuid string) (entity.TokenEntity, error) {
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		return entity.TokenEntity{}, err
+	}
+	created, err := m.challenges.Create(hash, uuid, m.challengeTTL, m.maxChallenges)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Unavailable("failed to create mfa challenge", err)
+	}
+	if !created {
+		return entity.TokenEntity{}, errs.TooManyRequests("too many mfa challenges", m.challengeTTL)
+	}
+	return entity.TokenEntity{MfaToken: token}, nil
+}
+
+func (m *mfaService) Login(mfaToken string, code string, client entity.ClientEntity) (entity.TokenEntity, error) {
+	if mfaToken == "" {
+		return entity.TokenEntity{}, errInvalidMfaToken
+	}
+	hash := hashRefreshToken(mfaToken)
+	uuid, found, err := m.challenges.Attempt(hash, m.maxAttempts)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Unavailable("failed to load mfa challenge", err)
+	}
+	if !found {
+		return entity.TokenEntity{}, errInvalidMfaToken
+	}
+	if err := m.lockout.Check(uuid, "", client.IP); err != nil {
+		return entity.TokenEntity{}, err
+	}
+	mfa, found, err := m.repository.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if found && mfa.Enabled {
+		ok, err := m.checkCode(mfa, code)
+		if err != nil {
+			return entity.TokenEntity{}, err
+		}
+		if !ok {
+			m.lockout.Failed(uuid, "", client.IP)
+			return entity.TokenEntity{}, errMfaLoginFailed
+		}
+	}
+	if err := m.challenges.Delete(hash); err != nil {
+		log.Println(err)
+	}
+	m.lockout.Succeeded(uuid)
+	return m.sessions.Start(uuid, client)
+}
+
+// checkCode accepts a TOTP code or, failing that, burns a recovery code.
+func (m *mfaService) checkCode(mfa entity.MfaEntity, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 6 {
+		return m.checkTotp(mfa, code)
+	}
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(normalized) != 10 {
+		return false, nil
+	}
+	ok, err := m.repository.UseRecoveryCode(mfa.Uuid, hashRecoveryCode(normalized))
+	if err != nil {
+		log.Println(err)
+		return false, errs.Unavailable("failed connect to DB", err)
+	}
+	return ok, nil
+}
+
+// checkTotp verifies code per RFC 6238 within the allowed skew and records
+// the matching time step, so the same code cannot be used twice.
+func (m *mfaService) checkTotp(mfa entity.MfaEntity, code string) (bool, error) {
+	secret, err := m.box.Open(mfa.Secret)
+	if err != nil {
+		log.Println(err)
+		return false, errs.Internal("failed to read mfa secret", err)
+	}
+	opts := totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	now := time.Now()
+	for offset := -m.skew; offset <= m.skew; offset++ {
+		t := now.Add(time.Duration(offset*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(string(secret), t, opts)
+		if err != nil {
+			log.Println(err)
+			return false, errs.Internal("failed to read mfa secret", err)
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+			continue
+		}
+		fresh, err := m.repository.UseStep(mfa.Uuid, t.Unix()/totpPeriod)
+		if err != nil {
+			log.Println(err)
+			return false, errs.Unavailable("failed connect to DB", err)
+		}
+		return fresh, nil
+	}
+	return false, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
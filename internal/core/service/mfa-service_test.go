@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const testTotpSecret = "JBSWY3DPEHPK3PXP"
+
+// challengeMap is a challenge store in memory.
+type challengeMap struct {
+	uuids    map[string]string
+	attempts map[string]int
+	started  map[string]int
+}
+
+func newChallengeMap() *challengeMap {
+	return &challengeMap{uuids: map[string]string{}, attempts: map[string]int{}, started: map[string]int{}}
+}
+
+func (c *challengeMap) Create(tokenHash string, uuid string, ttl time.Duration, maxChallenges int) (bool, error) {
+	c.started[uuid]++
+	if c.started[uuid] > maxChallenges {
+		return false, nil
+	}
+	c.uuids[tokenHash] = uuid
+	return true, nil
+}
+
+func (c *challengeMap) Attempt(tokenHash string, maxAttempts int) (string, bool, error) {
+	uuid, found := c.uuids[tokenHash]
+	if !found {
+		return "", false, nil
+	}
+	c.attempts[tokenHash]++
+	if c.attempts[tokenHash] > maxAttempts {
+		delete(c.uuids, tokenHash)
+		return "", false, nil
+	}
+	return uuid, true, nil
+}
+
+func (c *challengeMap) Delete(tokenHash string) error {
+	delete(c.uuids, tokenHash)
+	return nil
+}
+
+// totpFactor is the enabled factor of every user.
+type totpFactor struct {
+	port.MfaRepository
+}
+
+func (totpFactor) Get(uuid string) (entity.MfaEntity, bool, error) {
+	return entity.MfaEntity{Uuid: uuid, Secret: []byte(testTotpSecret), Enabled: true}, true, nil
+}
+
+func (totpFactor) UseStep(uuid string, step int64) (bool, error) { return true, nil }
+
+func (totpFactor) UseRecoveryCode(uuid string, hash string) (bool, error) { return false, nil }
+
+// noFactor has no user with MFA.
+type noFactor struct {
+	port.MfaRepository
+}
+
+func (noFactor) Get(uuid string) (entity.MfaEntity, bool, error) {
+	return entity.MfaEntity{}, false, nil
+}
+
+// plainBox leaves secrets as they are.
+type plainBox struct{}
+
+func (plainBox) Seal(plaintext []byte) ([]byte, error)  { return plaintext, nil }
+func (plainBox) Open(ciphertext []byte) ([]byte, error) { return ciphertext, nil }
+
+// lockoutLog records the outcome of logins and locks accounts on demand.
+type lockoutLog struct {
+	port.LockoutService
+	locked    map[string]bool
+	failed    []string
+	succeeded []string
+}
+
+func (l *lockoutLog) Check(uuid string, login string, ip string) error {
+	if l.locked[uuid] {
+		return errs.TooManyRequests("too many failed logins", time.Minute)
+	}
+	return nil
+}
+
+func (l *lockoutLog) Failed(uuid string, login string, ip string) { l.failed = append(l.failed, uuid) }
+func (l *lockoutLog) Succeeded(uuid string)                       { l.succeeded = append(l.succeeded, uuid) }
+
+func totpCode(t *testing.T) string {
+	code, err := totp.GenerateCodeCustom(testTotpSecret, time.Now(), totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestMfaLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		locked    bool
+		code      func(t *testing.T) string
+		err       error
+		failed    int
+		succeeded int
+	}{
+		{"right code", false, totpCode, nil, 0, 1},
+		{"wrong code", false, func(*testing.T) string { return "000000" }, errs.ErrUnauthorized, 1, 0},
+		{"wrong recovery code", false, func(*testing.T) string { return "aaaaa-bbbbb" }, errs.ErrUnauthorized, 1, 0},
+		{"account locked", true, totpCode, errs.ErrTooManyRequests, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lockout := &lockoutLog{locked: map[string]bool{"u1": tt.locked}}
+			m := NewMfaService(totpFactor{}, nil, newChallengeMap(), plainBox{}, sessionIssuer{}, lockout)
+			challenge, err := m.Challenge("u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := m.Login(challenge.MfaToken, tt.code(t), entity.ClientEntity{IP: "192.0.2.7"})
+			if tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && token.AccessToken != "token-of-u1" {
+				t.Errorf("token %+v, want a session of u1", token)
+			}
+			if len(lockout.failed) != tt.failed || len(lockout.succeeded) != tt.succeeded {
+				t.Errorf("failed %v succeeded %v, want %d and %d", lockout.failed, lockout.succeeded, tt.failed, tt.succeeded)
+			}
+		})
+	}
+}
+
+func TestMfaChallengesLimited(t *testing.T) {
+	t.Setenv("MFA_MAX_CHALLENGES", "3")
+	m := NewMfaService(totpFactor{}, nil, newChallengeMap(), plainBox{}, sessionIssuer{}, &lockoutLog{})
+	for i := 1; i <= 4; i++ {
+		_, err := m.Challenge("u1")
+		if i <= 3 && err != nil {
+			t.Fatalf("challenge %d: %v", i, err)
+		}
+		if i > 3 && !errors.Is(err, errs.ErrTooManyRequests) {
+			t.Fatalf("challenge %d: err = %v, want %v", i, err, errs.ErrTooManyRequests)
+		}
+	}
+	if _, err := m.Challenge("u2"); err != nil {
+		t.Errorf("challenges of u1 limited u2: %v", err)
+	}
+}
+
+// loginList is a user repository finding users by login.
+type loginList struct {
+	port.UserRepository
+	user entity.UserEntity
+}
+
+func (l loginList) FindByLogin(login string) (entity.UserEntity, bool, error) {
+	return l.user, login == l.user.Username, nil
+}
+
+// plainHasher stores passwords as they are.
+type plainHasher struct{}
+
+func (plainHasher) Hash(password string) (string, error) { return password, nil }
+func (plainHasher) Verify(encoded string, password string, uuid string) (bool, bool, error) {
+	return encoded == password, false, nil
+}
+
+func TestAuthenticateForgivesAfterSecondFactor(t *testing.T) {
+	user := entity.UserEntity{Uuid: "u1", Username: "koala", Password: "secret", Verified: true}
+	tests := []struct {
+		name      string
+		mfa       bool
+		succeeded int
+	}{
+		{"password only", false, 1},
+		{"second factor pending", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lockout := &lockoutLog{}
+			var factors port.MfaRepository = noFactor{}
+			if tt.mfa {
+				factors = totpFactor{}
+			}
+			mfa := NewMfaService(factors, nil, newChallengeMap(), plainBox{}, sessionIssuer{}, lockout)
+			s := &userService{repository: loginList{user: user}, hasher: plainHasher{}, lockout: lockout, mfa: mfa, sessions: sessionIssuer{}}
+			token, err := s.Authenticate(context.Background(), "koala", "secret", entity.ClientEntity{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.mfa != (token.MfaToken != "") {
+				t.Errorf("token %+v, want mfa %v", token, tt.mfa)
+			}
+			if len(lockout.succeeded) != tt.succeeded {
+				t.Errorf("failures forgiven %d times, want %d", len(lockout.succeeded), tt.succeeded)
+			}
+			if tt.mfa {
+				if _, err := mfa.Login(token.MfaToken, totpCode(t), entity.ClientEntity{}); err != nil {
+					t.Fatal(err)
+				}
+				if len(lockout.succeeded) != 1 {
+					t.Errorf("failures forgiven %d times after the second factor, want 1", len(lockout.succeeded))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// OutboxRelay publishes outbox messages to the event bus in the order they
+// were written. A message is marked sent only after the bus accepted it,
+// so delivery is at-least-once and pending messages survive restarts.
+type OutboxRelay struct {
+	outbox    port.OutboxRepository
+	publisher port.EventPublisher
+	interval  time.Duration
+	batch     int
+}
+
+// NewOutboxRelay creates a relay polling every OUTBOX_POLL_INTERVAL
+// (default 1s). It also relays whenever worker runs a PublishEventJob.
+func NewOutboxRelay(outbox port.OutboxRepository, publisher port.EventPublisher, worker port.Worker) *OutboxRelay {
+	interval, err := time.ParseDuration(os.Getenv("OUTBOX_POLL_INTERVAL"))
+	if err != nil {
+		interval = time.Second
+	}
+	r := &OutboxRelay{
+		outbox:    outbox,
+		publisher: publisher,
+		interval:  interval,
+		batch:     100,
+	}
+	worker.Handle(port.HandlerFunc(func(ctx context.Context, _ entity.PublishEventJob) error {
+		r.flush()
+		return nil
+	}))
+	return r
+}
+
+// Run relays until ctx is done.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.flush()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flush relays until the outbox is drained or publishing fails.
+func (r *OutboxRelay) flush() {
+	for {
+		sent, err := r.outbox.Relay(r.batch, r.publish)
+		if err != nil {
+			log.Println("outbox relay:", err)
+		}
+		if sent < r.batch {
+			return
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(event entity.OutboxEntity) error {
+	return r.publisher.Publish(context.Background(), event)
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+var (
+	errInvalidCeremony = errs.Invalid("id", "ceremony", "invalid ceremony")
+	errInvalidPasskey  = errs.Invalid("credential", "passkey", "invalid passkey")
+	errPasskeyNotFound = errs.NotFound("passkey not found")
+	// a failed login is always unauthorized, whatever broke
+	errPasskeyLoginFailed = errs.Unauthorized("invalid passkey")
+)
+
+// ceremonyState is what a ceremony remembers between begin and finish.
+type ceremonyState struct {
+	Uuid    string               `json:"uuid"`
+	Session webauthn.SessionData `json:"session"`
+}
+
+// passkeyUser presents a user and their passkeys to the WebAuthn library.
+// The user handle is the uuid.
+type passkeyUser struct {
+	user     entity.UserEntity
+	passkeys []entity.PasskeyEntity
+}
+
+func (u passkeyUser) WebAuthnID() []byte          { return []byte(u.user.Uuid) }
+func (u passkeyUser) WebAuthnName() string        { return u.user.Username }
+func (u passkeyUser) WebAuthnDisplayName() string { return u.user.Name }
+func (u passkeyUser) WebAuthnIcon() string        { return "" }
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.passkeys))
+	for _, passkey := range u.passkeys {
+		credentials = append(credentials, toCredential(passkey))
+	}
+	return credentials
+}
+
+type passkeyService struct {
+	webauthn   *webauthn.WebAuthn
+	repository port.PasskeyRepository
+	users      port.UserRepository
+	ceremonies port.CeremonyStore
+	sessions   port.SessionService
+	ttl        time.Duration
+}
+
+// NewPasskeyService creates the WebAuthn relying party for WEBAUTHN_RP_ID
+// (default localhost), named WEBAUTHN_RP_NAME and accepting the comma
+// separated WEBAUTHN_RP_ORIGINS (default http://localhost:3000). A ceremony
+// must finish within 5 minutes.
+func NewPasskeyService(repository port.PasskeyRepository, users port.UserRepository, ceremonies port.CeremonyStore, sessions port.SessionService) (port.PasskeyService, error) {
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		rpID = "localhost"
+	}
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = "Koala Chat"
+	}
+	origins := os.Getenv("WEBAUTHN_RP_ORIGINS")
+	if origins == "" {
+		origins = "http://localhost:3000"
+	}
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     strings.Split(origins, ","),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &passkeyService{
+		webauthn:   w,
+		repository: repository,
+		users:      users,
+		ceremonies: ceremonies,
+		sessions:   sessions,
+		ttl:        5 * time.Minute,
+	}, nil
+}
+
+func (p *passkeyService) BeginRegistration(uuid string) (entity.CeremonyEntity, error) {
+	user, err := p.load(uuid)
+	if err != nil {
+		return entity.CeremonyEntity{}, err
+	}
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.passkeys))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	options, session, err := p.webauthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return entity.CeremonyEntity{}, errs.Internal("failed to start ceremony", err)
+	}
+	return p.begin(uuid, options, session)
+}
+
+func (p *passkeyService) FinishRegistration(uuid string, result entity.CeremonyResultEntity) (entity.PasskeyEntity, error) {
+	state, err := p.finish(result.ID)
+	if err != nil {
+		return entity.PasskeyEntity{}, err
+	}
+	if state.Uuid != uuid {
+		return entity.PasskeyEntity{}, errInvalidCeremony
+	}
+	user, err := p.load(uuid)
+	if err != nil {
+		return entity.PasskeyEntity{}, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(result.Credential))
+	if err != nil {
+		return entity.PasskeyEntity{}, errInvalidPasskey
+	}
+	credential, err := p.webauthn.CreateCredential(user, state.Session, parsed)
+	if err != nil {
+		log.Println(err)
+		return entity.PasskeyEntity{}, errInvalidPasskey
+	}
+	name := strings.TrimSpace(result.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	passkey := entity.PasskeyEntity{
+		ID:              base64.RawURLEncoding.EncodeToString(credential.ID),
+		Uuid:            uuid,
+		Name:            name,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		CreatedAt:       time.Now(),
+	}
+	if err := p.repository.Save(passkey); err != nil {
+		log.Println(err)
+		return entity.PasskeyEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	return passkey, nil
+}
+
+func (p *passkeyService) BeginLogin() (entity.CeremonyEntity, error) {
+	options, session, err := p.webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		return entity.CeremonyEntity{}, errs.Internal("failed to start ceremony", err)
+	}
+	return p.begin("", options, session)
+}
+
+func (p *passkeyService) FinishLogin(result entity.CeremonyResultEntity, client entity.ClientEntity) (entity.TokenEntity, error) {
+	state, err := p.finish(result.ID)
+	if errors.Is(err, errs.ErrValidation) {
+		return entity.TokenEntity{}, errPasskeyLoginFailed
+	}
+	if err != nil {
+		return entity.TokenEntity{}, err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(result.Credential))
+	if err != nil {
+		return entity.TokenEntity{}, errPasskeyLoginFailed
+	}
+	var owner passkeyUser
+	credential, err := p.webauthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+		passkey, found, err := p.repository.Get(base64.RawURLEncoding.EncodeToString(rawID))
+		if err != nil {
+			return nil, err
+		}
+		if !found || passkey.Uuid != string(userHandle) {
+			return nil, errPasskeyNotFound
+		}
+		owner, err = p.load(passkey.Uuid)
+		return owner, err
+	}, state.Session, parsed)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errPasskeyLoginFailed
+	}
+	if credential.Authenticator.CloneWarning {
+		log.Printf("passkey of %s may be cloned, rejecting login\n", owner.user.Uuid)
+		return entity.TokenEntity{}, errPasskeyLoginFailed
+	}
+	id := base64.RawURLEncoding.EncodeToString(credential.ID)
+	if err := p.repository.Used(id, credential.Authenticator.SignCount, credential.Flags.BackupState, time.Now()); err != nil {
+		log.Println(err)
+	}
+	if !owner.user.Verified {
+		return entity.TokenEntity{}, errEmailNotVerified
+	}
+	return p.sessions.Start(owner.user.Uuid, client)
+}
+
+func (p *passkeyService) List(uuid string) ([]entity.PasskeyEntity, error) {
+	passkeys, err := p.repository.List(uuid)
+	if err != nil {
+		log.Println(err)
+		return nil, errs.Unavailable("failed connect to DB", err)
+	}
+	return passkeys, nil
+}
+
+func (p *passkeyService) Revoke(uuid string, id string) error {
+	deleted, err := p.repository.Delete(uuid, id)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	if !deleted {
+		return errPasskeyNotFound
+	}
+	return nil
+}
+
+func (p *passkeyService) load(uuid string) (passkeyUser, error) {
+	user, found, err := p.users.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return passkeyUser{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return passkeyUser{}, errs.NotFound("uuid not found")
+	}
+	passkeys, err := p.repository.List(uuid)
+	if err != nil {
+		log.Println(err)
+		return passkeyUser{}, errs.Unavailable("failed connect to DB", err)
+	}
+	return passkeyUser{user: user, passkeys: passkeys}, nil
+}
+
+func (p *passkeyService) begin(uuid string, options interface{}, session *webauthn.SessionData) (entity.CeremonyEntity, error) {
+	state, err := sonic.Marshal(&ceremonyState{Uuid: uuid, Session: *session})
+	if err != nil {
+		return entity.CeremonyEntity{}, err
+	}
+	b, err := sonic.Marshal(options)
+	if err != nil {
+		return entity.CeremonyEntity{}, err
+	}
+	id := newCeremonyID()
+	if err := p.ceremonies.Save(id, state, p.ttl); err != nil {
+		log.Println(err)
+		return entity.CeremonyEntity{}, errs.Unavailable("failed to start ceremony", err)
+	}
+	return entity.CeremonyEntity{ID: id, Options: b}, nil
+}
+
+func (p *passkeyService) finish(id string) (ceremonyState, error) {
+	if id == "" {
+		return ceremonyState{}, errInvalidCeremony
+	}
+	b, found, err := p.ceremonies.Take(id)
+	if err != nil {
+		log.Println(err)
+		return ceremonyState{}, errs.Unavailable("failed to load ceremony", err)
+	}
+	if !found {
+		return ceremonyState{}, errInvalidCeremony
+	}
+	var state ceremonyState
+	if err := sonic.Unmarshal(b, &state); err != nil {
+		return ceremonyState{}, err
+	}
+	return state, nil
+}
+
+func toCredential(passkey entity.PasskeyEntity) webauthn.Credential {
+	id, _ := base64.RawURLEncoding.DecodeString(passkey.ID)
+	var transports []protocol.AuthenticatorTransport
+	if passkey.Transports != "" {
+		for _, transport := range strings.Split(passkey.Transports, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+	}
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       passkey.PublicKey,
+		AttestationType: passkey.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: passkey.BackupEligible,
+			BackupState:    passkey.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    passkey.AAGUID,
+			SignCount: passkey.SignCount,
+		},
+	}
+}
+
+func newCeremonyID() string {
+	return uuid.New().String()
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// passkeyStore is a passkey repository in memory.
+type passkeyStore struct {
+	port.PasskeyRepository
+	passkeys map[string]entity.PasskeyEntity
+}
+
+func (s *passkeyStore) Save(passkey entity.PasskeyEntity) error {
+	s.passkeys[passkey.ID] = passkey
+	return nil
+}
+
+func (s *passkeyStore) Get(id string) (entity.PasskeyEntity, bool, error) {
+	passkey, found := s.passkeys[id]
+	return passkey, found, nil
+}
+
+func (s *passkeyStore) List(uuid string) ([]entity.PasskeyEntity, error) {
+	var passkeys []entity.PasskeyEntity
+	for _, passkey := range s.passkeys {
+		if passkey.Uuid == uuid {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+	return passkeys, nil
+}
+
+func (s *passkeyStore) Used(id string, signCount uint32, backupState bool, at time.Time) error {
+	passkey := s.passkeys[id]
+	passkey.SignCount = signCount
+	passkey.BackupState = backupState
+	s.passkeys[id] = passkey
+	return nil
+}
+
+// ceremonyMap is a ceremony store in memory.
+type ceremonyMap map[string][]byte
+
+func (c ceremonyMap) Save(id string, state []byte, ttl time.Duration) error {
+	c[id] = state
+	return nil
+}
+
+func (c ceremonyMap) Take(id string) ([]byte, bool, error) {
+	state, found := c[id]
+	delete(c, id)
+	return state, found, nil
+}
+
+// userList is a user repository holding users.
+type userList struct {
+	port.UserRepository
+	users map[string]entity.UserEntity
+}
+
+func (u *userList) Get(uuid string) (entity.UserEntity, bool, error) {
+	user, found := u.users[uuid]
+	return user, found, nil
+}
+
+// sessionIssuer starts a session for anyone.
+type sessionIssuer struct {
+	port.SessionService
+}
+
+func (sessionIssuer) Start(uuid string, client entity.ClientEntity) (entity.TokenEntity, error) {
+	return entity.TokenEntity{AccessToken: "token-of-" + uuid, TokenType: "Bearer"}, nil
+}
+
+// authenticator is a software passkey on one P-256 key, attesting with
+// "none" as platform authenticators mostly do.
+type authenticator struct {
+	t         *testing.T
+	origin    string
+	rpID      string
+	id        []byte
+	key       *ecdsa.PrivateKey
+	signCount uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	return &authenticator{t: t, origin: "http://localhost:3000", rpID: "localhost", id: id, key: key}
+}
+
+// challenge reads the challenge out of the options of a ceremony.
+func (a *authenticator) challenge(ceremony entity.CeremonyEntity) string {
+	var options struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	if err := sonic.Unmarshal(ceremony.Options, &options); err != nil {
+		a.t.Fatal(err)
+	}
+	return options.PublicKey.Challenge
+}
+
+func (a *authenticator) clientData(typ, challenge string) []byte {
+	b, err := sonic.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": a.origin})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return b
+}
+
+// authData is the authenticator data with user present and verified flags.
+func (a *authenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags|0x05)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+// create answers navigator.credentials.create for challenge.
+func (a *authenticator) create(ceremonyID, challenge string) entity.CeremonyResultEntity {
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.key.PublicKey.X.FillBytes(x)
+	a.key.PublicKey.Y.FillBytes(y)
+	// EC2 key on P-256 for ES256
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(append(attested, a.id...), publicKey...)
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(0x40, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.result(ceremonyID, map[string]string{
+		"clientDataJSON":    encode(a.clientData("webauthn.create", challenge)),
+		"attestationObject": encode(attestation),
+	})
+}
+
+// get answers navigator.credentials.get for challenge as user uuid.
+func (a *authenticator) get(ceremonyID, challenge, uuid string) entity.CeremonyResultEntity {
+	clientData := a.clientData("webauthn.get", challenge)
+	authData := a.authData(0, nil)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), hash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.result(ceremonyID, map[string]string{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode([]byte(uuid)),
+	})
+}
+
+func (a *authenticator) result(ceremonyID string, response map[string]string) entity.CeremonyResultEntity {
+	credential, err := sonic.Marshal(map[string]interface{}{
+		"id":       encode(a.id),
+		"rawId":    encode(a.id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return entity.CeremonyResultEntity{ID: ceremonyID, Credential: credential}
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newTestPasskeyService(t *testing.T) (*passkeyStore, port.PasskeyService) {
+	t.Setenv("WEBAUTHN_RP_ID", "")
+	t.Setenv("WEBAUTHN_RP_ORIGINS", "")
+	store := &passkeyStore{passkeys: map[string]entity.PasskeyEntity{}}
+	users := &userList{users: map[string]entity.UserEntity{
+		"u1": {Uuid: "u1", Username: "koala", Name: "Kö Ala", Verified: true},
+	}}
+	p, err := NewPasskeyService(store, users, ceremonyMap{}, sessionIssuer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, p
+}
+
+// register runs a registration ceremony of a for u1.
+func register(t *testing.T, p port.PasskeyService, a *authenticator) entity.PasskeyEntity {
+	ceremony, err := p.BeginRegistration("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passkey, err := p.FinishRegistration("u1", a.create(ceremony.ID, a.challenge(ceremony)))
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	return passkey
+}
+
+func TestPasskeyRoundTrip(t *testing.T) {
+	store, p := newTestPasskeyService(t)
+	a := newAuthenticator(t)
+	a.signCount = 1
+	passkey := register(t, p, a)
+	if passkey.ID != encode(a.id) || passkey.Uuid != "u1" || passkey.SignCount != 1 {
+		t.Errorf("registered %+v", passkey)
+	}
+
+	for _, count := range []uint32{2, 7} {
+		a.signCount = count
+		ceremony, err := p.BeginLogin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := p.FinishLogin(a.get(ceremony.ID, a.challenge(ceremony), "u1"), entity.ClientEntity{})
+		if err != nil {
+			t.Fatalf("login with count %d failed: %v", count, err)
+		}
+		if token.AccessToken != "token-of-u1" {
+			t.Errorf("token %+v, want a session of u1", token)
+		}
+		if got := store.passkeys[passkey.ID].SignCount; got != count {
+			t.Errorf("stored sign count %d, want %d", got, count)
+		}
+	}
+}
+
+func TestPasskeyLoginRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		login  func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity
+		stored uint32
+	}{
+		{
+			name: "sign count regressed",
+			login: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				a.signCount = 4
+				ceremony, _ := p.BeginLogin()
+				return a.get(ceremony.ID, a.challenge(ceremony), "u1")
+			},
+			stored: 5,
+		},
+		{
+			name: "sign count repeated",
+			login: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				a.signCount = 5
+				ceremony, _ := p.BeginLogin()
+				return a.get(ceremony.ID, a.challenge(ceremony), "u1")
+			},
+			stored: 5,
+		},
+		{
+			name: "wrong challenge",
+			login: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				a.signCount = 6
+				ceremony, _ := p.BeginLogin()
+				return a.get(ceremony.ID, encode([]byte("not the challenge of this ceremony")), "u1")
+			},
+			stored: 5,
+		},
+		{
+			name: "challenge of another ceremony",
+			login: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				a.signCount = 6
+				other, _ := p.BeginLogin()
+				ceremony, _ := p.BeginLogin()
+				return a.get(ceremony.ID, a.challenge(other), "u1")
+			},
+			stored: 5,
+		},
+		{
+			name: "ceremony replayed",
+			login: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				a.signCount = 6
+				ceremony, _ := p.BeginLogin()
+				result := a.get(ceremony.ID, a.challenge(ceremony), "u1")
+				if _, err := p.FinishLogin(result, entity.ClientEntity{}); err != nil {
+					a.t.Fatal(err)
+				}
+				a.signCount = 7
+				return a.get(ceremony.ID, a.challenge(ceremony), "u1")
+			},
+			stored: 6,
+		},
+		{
+			name: "user handle of someone else",
+			login: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				a.signCount = 6
+				ceremony, _ := p.BeginLogin()
+				return a.get(ceremony.ID, a.challenge(ceremony), "u2")
+			},
+			stored: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, p := newTestPasskeyService(t)
+			a := newAuthenticator(t)
+			a.signCount = 5
+			passkey := register(t, p, a)
+
+			_, err := p.FinishLogin(tt.login(p, a), entity.ClientEntity{})
+			if !errors.Is(err, errPasskeyLoginFailed) {
+				t.Errorf("err = %v, want %v", err, errPasskeyLoginFailed)
+			}
+			if got := store.passkeys[passkey.ID].SignCount; got != tt.stored {
+				t.Errorf("stored sign count %d, want %d", got, tt.stored)
+			}
+		})
+	}
+}
+
+func TestPasskeyRegistrationRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity
+	}{
+		{
+			name: "wrong challenge",
+			register: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				ceremony, _ := p.BeginRegistration("u1")
+				return a.create(ceremony.ID, encode([]byte("not the challenge of this ceremony")))
+			},
+		},
+		{
+			name: "foreign origin",
+			register: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				ceremony, _ := p.BeginRegistration("u1")
+				a.origin = "https://evil.example"
+				return a.create(ceremony.ID, a.challenge(ceremony))
+			},
+		},
+		{
+			name: "another relying party",
+			register: func(p port.PasskeyService, a *authenticator) entity.CeremonyResultEntity {
+				ceremony, _ := p.BeginRegistration("u1")
+				a.rpID = "evil.example"
+				return a.create(ceremony.ID, a.challenge(ceremony))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, p := newTestPasskeyService(t)
+			_, err := p.FinishRegistration("u1", tt.register(p, newAuthenticator(t)))
+			if !errors.Is(err, errInvalidPasskey) {
+				t.Errorf("err = %v, want %v", err, errInvalidPasskey)
+			}
+			if len(store.passkeys) != 0 {
+				t.Errorf("saved %d passkeys, want none", len(store.passkeys))
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+var errInvalidResetToken = errs.Invalid("token", "token", "invalid reset token")
+
+// passwordResetter issues the single-use links that let a user who forgot
+// their password set a new one.
+type passwordResetter struct {
+	store    port.PasswordResetStore
+	mailer   port.Mailer
+	link     string
+	ttl      time.Duration
+	interval time.Duration
+}
+
+// newPasswordResetter reads PASSWORD_RESET_URL, the page the link opens
+// with ?token=, PASSWORD_RESET_TTL (default 15m) and
+// PASSWORD_RESET_INTERVAL, the minimum time between two reset mails to the
+// same account (default 1m).
+func newPasswordResetter(store port.PasswordResetStore, mailer port.Mailer) *passwordResetter {
+	link := os.Getenv("PASSWORD_RESET_URL")
+	if link == "" {
+		link = "http://localhost:3000/password/reset"
+	}
+	ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL"))
+	if err != nil {
+		ttl = 15 * time.Minute
+	}
+	interval, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_INTERVAL"))
+	if err != nil {
+		interval = time.Minute
+	}
+	return &passwordResetter{
+		store:    store,
+		mailer:   mailer,
+		link:     link,
+		ttl:      ttl,
+		interval: interval,
+	}
+}
+
+// ForgotPassword queues the reset mail without looking the account up, so
+// the answer and its timing are the same whether email is registered or not.
+func (s *userService) ForgotPassword(email string) error {
+	email = normalizeEmail(email)
+	if err := s.validator.Validate(entity.ForgotPasswordEntity{Email: email}); err != nil {
+		return nil
+	}
+	if err := s.worker.Submit(context.TODO(), entity.SendPasswordResetJob{Email: email}); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+func (s *userService) sendPasswordReset(ctx context.Context, job entity.SendPasswordResetJob) error {
+	user, found, err := s.repository.GetByEmail(job.Email)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	allowed, err := s.resetter.store.Throttle(user.Uuid, s.resetter.interval)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		log.Printf("password reset for %s throttled\n", user.Uuid)
+		return nil
+	}
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		return err
+	}
+	if err := s.resetter.store.Create(hash, user.Uuid, s.resetter.ttl); err != nil {
+		return err
+	}
+	return s.resetter.mailer.Send(ctx, entity.MailEntity{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Open the link below to choose a new password:\n\n" +
+			s.resetter.link + "?token=" + url.QueryEscape(token) + "\n\n" +
+			"The link expires in " + s.resetter.ttl.String() + ". If you did not ask for it, ignore this mail.\n",
+	})
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and
+// signs the account out everywhere.
+func (s *userService) ResetPassword(ctx context.Context, token string, password string) error {
+	if err := s.validator.Validate(entity.ResetPasswordEntity{Token: token, Password: password}); err != nil {
+		return err
+	}
+	tokenHash := hashRefreshToken(token)
+	uuid, found, err := s.resetter.store.Peek(tokenHash)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to load reset token", err)
+	}
+	if !found {
+		return errInvalidResetToken
+	}
+	// check before the token is spent, so a rejected password can be retried
+	user, err := s.current(uuid)
+	if err != nil {
+		return err
+	}
+	if err := s.checkPassword(password, user); err != nil {
+		return err
+	}
+	hash, err := s.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	consumed, found, err := s.resetter.store.Consume(tokenHash)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to load reset token", err)
+	}
+	if !found || consumed != uuid {
+		return errInvalidResetToken
+	}
+	event, err := s.newUserEvent(ctx, "password_reset", entity.UserEntity{Uuid: uuid})
+	if err != nil {
+		return err
+	}
+	reset, err := s.repository.ResetPassword(uuid, hash, event)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	if !reset {
+		return errInvalidResetToken
+	}
+	if err := s.sessions.RevokeAll(uuid); err != nil {
+		log.Println(err)
+	}
+	if err := s.invalidate(uuid); err != nil {
+		log.Println(err)
+	}
+	if err := s.worker.Submit(context.TODO(), entity.PublishEventJob{}); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+var (
+	errInvalidRefreshToken = errs.Unauthorized("invalid refresh token")
+	errSessionNotFound     = errs.NotFound("session not found")
+)
+
+type sessionService struct {
+	store  port.SessionStore
+	tokens port.TokenIssuer
+	ttl    time.Duration
+}
+
+// NewSessionService creates the service managing login sessions. Refresh
+// tokens live for REFRESH_TOKEN_TTL (default 30 days) past their last use.
+func NewSessionService(store port.SessionStore, tokens port.TokenIssuer) port.SessionService {
+	ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL"))
+	if err != nil {
+		ttl = 30 * 24 * time.Hour
+	}
+	return &sessionService{
+		store:  store,
+		tokens: tokens,
+		ttl:    ttl,
+	}
+}
+
+// Start opens a new session for uuid and returns its first token pair.
+func (s *sessionService) Start(uuid string, client entity.ClientEntity) (entity.TokenEntity, error) {
+	refresh, hash, err := newRefreshToken()
+	if err != nil {
+		return entity.TokenEntity{}, err
+	}
+	now := time.Now()
+	session := entity.SessionEntity{
+		ID:         newSessionID(),
+		Uuid:       uuid,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+		TokenHash:  hash,
+	}
+	if err := s.store.Create(session); err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Unavailable("failed to create session", err)
+	}
+	return s.issue(session, refresh)
+}
+
+// Refresh exchanges a refresh token for a new token pair. A token that was
+// already rotated out means it leaked, so the whole session is revoked.
+func (s *sessionService) Refresh(refreshToken string, client entity.ClientEntity) (entity.TokenEntity, error) {
+	oldHash := hashRefreshToken(refreshToken)
+	session, found, err := s.store.Lookup(oldHash)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Unavailable("failed to load session", err)
+	}
+	if !found || time.Now().After(session.ExpiresAt) {
+		return entity.TokenEntity{}, errInvalidRefreshToken
+	}
+	if session.TokenHash != oldHash {
+		s.revokeReused(session)
+		return entity.TokenEntity{}, errInvalidRefreshToken
+	}
+	refresh, hash, err := newRefreshToken()
+	if err != nil {
+		return entity.TokenEntity{}, err
+	}
+	now := time.Now()
+	session.TokenHash = hash
+	session.IP = client.IP
+	session.UserAgent = client.UserAgent
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(s.ttl)
+	rotated, err := s.store.Rotate(session, oldHash)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Unavailable("failed to rotate session", err)
+	}
+	if !rotated {
+		// a concurrent refresh won with the same token
+		s.revokeReused(session)
+		return entity.TokenEntity{}, errInvalidRefreshToken
+	}
+	return s.issue(session, refresh)
+}
+
+func (s *sessionService) Logout(refreshToken string) error {
+	session, found, err := s.store.Lookup(hashRefreshToken(refreshToken))
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to load session", err)
+	}
+	if !found {
+		return errInvalidRefreshToken
+	}
+	if err := s.store.Revoke(session.ID); err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to revoke session", err)
+	}
+	return nil
+}
+
+func (s *sessionService) List(uuid string) ([]entity.SessionEntity, error) {
+	sessions, err := s.store.List(uuid)
+	if err != nil {
+		log.Println(err)
+		return nil, errs.Unavailable("failed to load sessions", err)
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) Revoke(uuid string, sessionID string) error {
+	sessions, err := s.List(uuid)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID != sessionID {
+			continue
+		}
+		if err := s.store.Revoke(sessionID); err != nil {
+			log.Println(err)
+			return errs.Unavailable("failed to revoke session", err)
+		}
+		return nil
+	}
+	return errSessionNotFound
+}
+
+func (s *sessionService) RevokeAll(uuid string) error {
+	return s.store.RevokeAll(uuid)
+}
+
+func (s *sessionService) issue(session entity.SessionEntity, refresh string) (entity.TokenEntity, error) {
+	access, expiresAt, err := s.tokens.Issue(session.Uuid, session.ID)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Internal("failed to issue token", err)
+	}
+	return entity.TokenEntity{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+func (s *sessionService) revokeReused(session entity.SessionEntity) {
+	log.Printf("refresh token reuse detected, revoking session %s of %s\n", session.ID, session.Uuid)
+	if err := s.store.Revoke(session.ID); err != nil {
+		log.Println(err)
+	}
+}
+
+func newSessionID() string {
+	return uuid.New().String()
+}
+
+// newRefreshToken returns an opaque refresh token and the hash it is stored
+// under. The token has 256 bits of entropy so a plain SHA-256 is enough.
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	usereventv1 "github.com/koalachatapp/user/internal/core/domain/userevent/v1"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	userEventSchema      = "urn:koalachat:proto:userevent.v1.UserEvent"
+	securityEventSchema  = "urn:koalachat:proto:securityevent.v1.LoginLock"
+	userEventContentType = "application/protobuf"
+)
+
+// userEventTypes are the CloudEvents types of the methods that change a
+// user. The suffix is the version of the data schema.
+var userEventTypes = map[string]string{
+	"register":       "app.koalachat.user.registered.v1",
+	"update":         "app.koalachat.user.updated.v1",
+	"patch":          "app.koalachat.user.patched.v1",
+	"delete":         "app.koalachat.user.deleted.v1",
+	"verify":         "app.koalachat.user.email_verified.v1",
+	"password_reset": "app.koalachat.user.password_reset.v1",
+}
+
+// securityEventTypes are the CloudEvents types of the lockout changes,
+// published on SecurityTopic unless EVENT_ROUTES sends them elsewhere.
+var securityEventTypes = map[string]string{
+	"lockout": "app.koalachat.security.login_locked.v1",
+	"unlock":  "app.koalachat.security.login_unlocked.v1",
+}
+
+// userEvents builds the CloudEvents of user and lockout changes. Their
+// source is EVENT_SOURCE (default /koalachat/user); personal data is
+// cleared unless EVENT_INCLUDE_PII is true.
+type userEvents struct {
+	source     string
+	includePII bool
+	topic      string
+	routes     map[string]string
+}
+
+// newUserEvents publishes user events on EVENT_TOPIC (default
+// UsersearchTopic) and lockout events on SecurityTopic, except for the
+// types EVENT_ROUTES sends elsewhere, given as a comma separated list of
+// type=topic, such as "app.koalachat.user.deleted.v1=UserDeletedTopic".
+func newUserEvents() *userEvents {
+	source := os.Getenv("EVENT_SOURCE")
+	if source == "" {
+		source = "/koalachat/user"
+	}
+	topic := os.Getenv("EVENT_TOPIC")
+	if topic == "" {
+		topic = "UsersearchTopic"
+	}
+	routes := map[string]string{}
+	for _, typ := range securityEventTypes {
+		routes[typ] = "SecurityTopic"
+	}
+	for _, route := range strings.Split(os.Getenv("EVENT_ROUTES"), ",") {
+		if strings.TrimSpace(route) == "" {
+			continue
+		}
+		typ, to, ok := strings.Cut(route, "=")
+		typ, to = strings.TrimSpace(typ), strings.TrimSpace(to)
+		if !ok || typ == "" || to == "" {
+			log.SetPrefix("[Warning] ")
+			log.Println("ignoring malformed EVENT_ROUTES entry " + route)
+			continue
+		}
+		routes[typ] = to
+	}
+	return &userEvents{
+		source:     source,
+		includePII: os.Getenv("EVENT_INCLUDE_PII") == "true",
+		topic:      topic,
+		routes:     routes,
+	}
+}
+
+// topicOf returns the topic events of type typ are published on.
+func (e *userEvents) topicOf(typ string) string {
+	if topic, ok := e.routes[typ]; ok {
+		return topic
+	}
+	return e.topic
+}
+
+// newUserEvent wraps the change method made to user in a CloudEvent for
+// the outbox. The actor defaults to the user itself, for the changes made
+// without signing in.
+func (s *userService) newUserEvent(ctx context.Context, method string, user entity.UserEntity) (entity.OutboxEntity, error) {
+	data := &usereventv1.UserEvent{
+		User: &usereventv1.User{
+			Uuid:     user.Uuid,
+			Username: user.Username,
+			Name:     user.Name,
+			Email:    user.Email,
+			Verified: user.Verified,
+		},
+	}
+	if !user.CreatedAt.IsZero() {
+		data.User.CreatedAt = timestamppb.New(user.CreatedAt)
+	}
+	if !s.events.includePII {
+		redact(data.ProtoReflect())
+	}
+	trace := entity.TraceFrom(ctx)
+	if trace.Actor == "" {
+		trace.Actor = user.Uuid
+	}
+	return s.events.cloudEvent(trace, userEventTypes[method], user.Uuid, userEventSchema, data)
+}
+
+// cloudEvent wraps data, the event of type typ about subject, in a
+// CloudEvent for the outbox keyed by subject.
+func (e *userEvents) cloudEvent(trace entity.TraceEntity, typ string, subject string, schema string, data proto.Message) (entity.OutboxEntity, error) {
+	payload, err := proto.Marshal(data)
+	if err != nil {
+		return entity.OutboxEntity{}, err
+	}
+	headers := map[string]string{
+		"ce_specversion": "1.0",
+		"ce_id":          uuid.New().String(),
+		"ce_source":      e.source,
+		"ce_type":        typ,
+		"ce_time":        time.Now().UTC().Format(time.RFC3339Nano),
+		"ce_dataschema":  schema,
+		"content-type":   userEventContentType,
+	}
+	if subject != "" {
+		headers["ce_subject"] = subject
+	}
+	if trace.Actor != "" {
+		headers["ce_actor"] = trace.Actor
+	}
+	if trace.CorrelationID != "" {
+		headers["ce_correlationid"] = trace.CorrelationID
+	}
+	b, err := sonic.Marshal(headers)
+	if err != nil {
+		return entity.OutboxEntity{}, err
+	}
+	return entity.OutboxEntity{
+		Topic:   e.topicOf(typ),
+		Key:     subject,
+		Payload: payload,
+		Headers: b,
+	}, nil
+}
+
+// structuredEvent renders a user event as a structured mode CloudEvent,
+// with its protobuf data as JSON, for the consumers that do not speak
+// protobuf.
+func structuredEvent(headers map[string]string, event entity.OutboxEntity) ([]byte, error) {
+	var data usereventv1.UserEvent
+	if err := proto.Unmarshal(event.Payload, &data); err != nil {
+		return nil, err
+	}
+	b, err := protojson.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+	return envelope(headers, event, b)
+}
+
+// envelope wraps the JSON data of event in a structured mode CloudEvent
+// with the attributes in headers.
+func envelope(headers map[string]string, event entity.OutboxEntity, data []byte) ([]byte, error) {
+	body := map[string]interface{}{}
+	for key, value := range headers {
+		if attribute, ok := strings.CutPrefix(key, "ce_"); ok {
+			body[attribute] = value
+		}
+	}
+	if event.Sequence > 0 {
+		body["sequence"] = fmt.Sprintf("%020d", event.Sequence)
+	}
+	body["datacontenttype"] = "application/json"
+	body["data"] = json.RawMessage(data)
+	return sonic.Marshal(body)
+}
+
+// redact clears every field of m marked (pii) = true, descending into
+// nested messages.
+func redact(m protoreflect.Message) {
+	var personal []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if proto.GetExtension(fd.Options(), usereventv1.E_Pii).(bool) {
+			personal = append(personal, fd)
+		} else if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+			redact(v.Message())
+		}
+		return true
+	})
+	for _, fd := range personal {
+		m.Clear(fd)
+	}
+}
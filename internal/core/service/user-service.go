@@ -2,106 +2,92 @@ package service
 
 import (
 	"context"
-	"crypto/sha512"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/Shopify/sarama"
 	"github.com/bytedance/sonic"
 	"github.com/go-redis/redis/v9"
 	"github.com/google/uuid"
 	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
 	"github.com/koalachatapp/user/internal/core/port"
+	"golang.org/x/text/unicode/norm"
 )
 
 type userService struct {
-	repository port.UserRepository
-	worker     *port.Worker
-	prod       sarama.AsyncProducer
-	redis      *redis.Client
+	repository   port.UserRepository
+	worker       port.Worker
+	hasher       port.PasswordHasher
+	policy       port.PasswordPolicy
+	validator    port.Validator
+	sessions     port.SessionService
+	mfa          port.MfaService
+	lockout      port.LockoutService
+	redis        *redis.Client
+	verifier     *emailVerifier
+	resetter     *passwordResetter
+	availability *availabilityChecker
+	events       *userEvents
 }
 
-var storage []func() error
+var (
+	errInvalidCredentials = errs.Unauthorized("invalid credentials")
+	errEmailNotVerified   = errs.Forbidden("email not verified")
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// dummyHash is verified against when a login names an unknown account, so
+// unknown and known accounts take the same time to reject.
+var dummyHash struct {
+	once sync.Once
+	hash string
+}
 
-// NewUserService creates a new user service
-func NewUserService(repository port.UserRepository, worker *port.Worker) port.UserService {
+// NewUserService creates a new user service caching users in cache, the
+// process wide redis client.
+func NewUserService(repository port.UserRepository, worker port.Worker, hasher port.PasswordHasher, policy port.PasswordPolicy, validator port.Validator, sessions port.SessionService, mfa port.MfaService, lockout port.LockoutService, verifications port.VerificationStore, resets port.PasswordResetStore, mailer port.Mailer, availability port.AvailabilityCache, cache *redis.Client) port.UserService {
 	userservice := &userService{
-		repository: repository,
-		worker:     worker,
-	}
-	userservice.redis = redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
-
-	// worker
-	go func(u *userService) {
-		for i := 0; i < 10; i++ {
-			go func(msg <-chan map[uint8]interface{}, wg *sync.WaitGroup) {
-				for msg := range msg {
-					for k, v := range msg {
-						switch k {
-						case 0:
-							err := v.(func() error)()
-							if err != nil {
-								log.Println(err)
-								storage = append(storage, v.(func() error))
-							}
-						case 1:
-							log.Println("Sending to Kafka : ", fmt.Sprintf("%s", v))
-							(u.worker.Prod).SendMessage(&sarama.ProducerMessage{
-								Topic: "UsersearchTopic",
-								Value: sarama.StringEncoder(fmt.Sprintf("%s", v)),
-							})
-						}
-					}
-					wg.Done()
-				}
-			}(u.worker.Worker, &u.worker.Wg)
-		}
-	}(userservice)
-	// retry mechanism
-	go func(u *userService) {
-		for {
-			if len(storage) > 0 {
-				log.Printf("rerunning %d pending task..\n", len(storage))
-				for i := 0; i < len(storage); i++ {
-					u.worker.Wg.Add(1)
-					u.worker.Worker <- map[uint8]interface{}{
-						0: storage[i],
-					}
-				}
-				storage = nil
-			}
-			time.Sleep(3 * time.Second)
-		}
-	}(userservice)
+		repository:   repository,
+		worker:       worker,
+		hasher:       hasher,
+		policy:       policy,
+		validator:    validator,
+		sessions:     sessions,
+		mfa:          mfa,
+		lockout:      lockout,
+		redis:        cache,
+		verifier:     newEmailVerifier(verifications, mailer),
+		resetter:     newPasswordResetter(resets, mailer),
+		availability: newAvailabilityChecker(availability),
+		events:       newUserEvents(),
+	}
+
+	worker.Handle(port.HandlerFunc(userservice.persistUser))
+	worker.Handle(port.HandlerFunc(userservice.updatePassword))
+	worker.Handle(port.HandlerFunc(userservice.invalidateCache))
+	worker.Handle(port.HandlerFunc(userservice.verifier.send))
+	worker.Handle(port.HandlerFunc(userservice.resendVerification))
+	worker.Handle(port.HandlerFunc(userservice.sendPasswordReset))
 
 	return userservice
 }
 
-func (s *userService) Register(user entity.UserEntity) (string, error) {
-	if err := validateNotEmpty(
-		[2]string{"username", user.Username},
-		[2]string{"password", user.Password},
-		[2]string{"email", user.Email},
-		[2]string{"name", user.Name},
-	); err != nil {
+func (s *userService) Register(ctx context.Context, user entity.UserEntity) (string, error) {
+	user = normalizeUser(user)
+	if err := s.validator.Validate(user); err != nil {
 		return "", err
 	}
-	isvalid := make(chan bool, 5)
-	go func() {
-		isvalid <- validateEmail(user.Email)
-	}()
-	if !<-isvalid {
-		return "", errors.New("invalid email address")
+	if err := s.checkPassword(user.Password, user); err != nil {
+		return "", err
 	}
 	email := s.redis.Get(context.TODO(), user.Email)
 	username := s.redis.Get(context.TODO(), user.Username)
@@ -109,10 +95,10 @@ func (s *userService) Register(user entity.UserEntity) (string, error) {
 		exist, err := s.repository.IsExist(user.Username, user.Email)
 		if err != nil {
 			log.Println(err)
-			return "", errors.New("failed connect to DB")
+			return "", errs.Unavailable("failed connect to DB", err)
 		}
 		if exist {
-			return "", errors.New("user already registered")
+			return "", errs.Conflict("user already registered")
 		}
 		u := uuid.New().String()
 		user.Uuid = strings.TrimSpace(string(u))
@@ -120,49 +106,29 @@ func (s *userService) Register(user entity.UserEntity) (string, error) {
 		s.redis.SetNX(context.Background(), user.Username, true, time.Minute)
 	}
 	if email.Val() != "" || username.Val() != "" {
-		return "", errors.New("user already registered")
-	}
-	s.worker.Wg.Add(1)
-	s.worker.Worker <- map[uint8]interface{}{
-		0: func() error {
-			sha := sha512.New()
-			var reverseUUID2byte []byte
-			for i := len([]rune(user.Uuid)) - 1; i == 0; i-- {
-				reverseUUID2byte = append(reverseUUID2byte, user.Uuid[i])
-			}
-			sha.Write([]byte(user.Uuid + "." + base64.StdEncoding.EncodeToString(reverseUUID2byte) + "." + user.Password))
-			user.Password = base64.RawURLEncoding.EncodeToString(sha.Sum(nil))
-			b, err := sonic.Marshal(&user)
-			if err != nil {
-				return err
-			}
-			success, err := s.redis.SetNX(context.Background(), user.Uuid, b, 1*time.Minute).Result()
-			if err != nil {
-				return err
-			}
-			if success {
-				log.Println("chaching on redis")
-			}
-			data := entity.UserEventEntity{
-				Method: "register",
-				Data:   user,
-			}
-			json, err := sonic.Marshal(&data)
-			if err != nil {
-				return err
-			}
-			s.worker.Wg.Add(1)
-			s.worker.Worker <- map[uint8]interface{}{
-				1: json,
-			}
-			return s.repository.Save(user)
-		},
+		return "", errs.Conflict("user already registered")
+	}
+	hash, err := s.hashPassword(user.Password)
+	if err != nil {
+		return "", err
 	}
+	user.Password = hash
+	user.Verified = false
+	user.CreatedAt = time.Now()
+	event, err := s.newUserEvent(ctx, "register", user)
+	if err != nil {
+		return "", err
+	}
+	if err := s.worker.Submit(ctx, entity.PersistUserJob{Op: "register", Uuid: user.Uuid, User: user, Event: event}); err != nil {
+		log.Println(err)
+		return "", errs.Unavailable("failed to queue user", err)
+	}
+	s.reserve(user)
 
 	return user.Uuid, nil
 }
 
-func (s *userService) Delete(uuid string) error {
+func (s *userService) Delete(ctx context.Context, uuid string) error {
 	if err := validateNotEmpty(
 		[2]string{"uuid", uuid},
 	); err != nil {
@@ -173,158 +139,439 @@ func (s *userService) Delete(uuid string) error {
 	}
 	res, err := s.redis.Del(context.TODO(), uuid).Result()
 	if err != nil {
-		return err
+		log.Println(err)
+		return errs.Unavailable("failed to delete user", err)
 	}
 	log.Println(res)
-	success, err := s.repository.Delete(uuid)
+	event, err := s.newUserEvent(ctx, "delete", entity.UserEntity{Uuid: uuid})
+	if err != nil {
+		return err
+	}
+	success, err := s.repository.Delete(uuid, event)
 	if !success {
 		if err != nil {
 			log.Println(err)
-			return errors.New("failed to delete user")
+			return errs.Unavailable("failed to delete user", err)
 		}
-		return errors.New("uuid not found")
-	}
-	var user entity.UserEntity = entity.UserEntity{
-		Uuid: uuid,
-	}
-	data := entity.UserEventEntity{
-		Method: "delete",
-		Data:   user,
+		return errs.NotFound("uuid not found")
 	}
-	json, _ := sonic.Marshal(&data)
-	if err != nil {
-		return err
-	}
-	s.worker.Wg.Add(1)
-	s.worker.Worker <- map[uint8]interface{}{
-		1: json,
+	// refresh tokens would otherwise keep minting access tokens
+	if err := s.sessions.RevokeAll(uuid); err != nil {
+		log.Println(err)
 	}
 	return nil
 }
 
-func (s *userService) Update(uuid string, user entity.UserEntity) error {
-	if err := validateNotEmpty(
-		[2]string{"uuid", uuid},
-		[2]string{"username", user.Username},
-		[2]string{"password", user.Password},
-		[2]string{"email", user.Email},
-		[2]string{"name", user.Name},
-	); err != nil {
+func (s *userService) Update(ctx context.Context, uuid string, user entity.UserEntity) error {
+	user.CreatedAt = time.Time{}
+	if err := validateNotEmpty([2]string{"uuid", uuid}); err != nil {
 		return err
 	}
-	if !validateEmail(user.Email) {
-		return errors.New("invalid email address")
+	user = normalizeUser(user)
+	if err := s.validator.Validate(user); err != nil {
+		return err
 	}
-	cached, err := s.checkUuid(uuid)
+	current, err := s.current(uuid)
 	if err != nil {
 		return err
 	}
-	if !cached {
-		b, err := sonic.Marshal(&user)
+	if err := s.checkPassword(user.Password, user); err != nil {
+		return err
+	}
+	reverify := user.Email != current.Email
+	if user.Password != "" {
+		hash, err := s.hashPassword(user.Password)
 		if err != nil {
 			return err
-		} else {
-			s.redis.SetNX(context.Background(), uuid, b, 2*time.Minute)
-			log.Printf("chacing uuid on redis [%s]\n", uuid)
 		}
-	}
-	if user.Password != "" {
-		sha := sha512.New()
-		sha.Write([]byte(uuid + "." + base64.StdEncoding.EncodeToString([]byte(uuid)) + "." + user.Password))
-		user.Password = base64.RawURLEncoding.EncodeToString(sha.Sum(nil))
-	}
-	s.worker.Wg.Add(1)
-	s.worker.Worker <- map[uint8]interface{}{
-		0: func() error {
-			return s.repository.Update(uuid, user)
-		},
+		user.Password = hash
 	}
 	user.Uuid = uuid
-	data := entity.UserEventEntity{
-		Method: "update",
-		Data:   user,
-	}
-	json, _ := sonic.Marshal(&data)
+	user.Verified = current.Verified && !reverify
+	event, err := s.newUserEvent(ctx, "update", user)
 	if err != nil {
 		return err
 	}
-	s.worker.Wg.Add(1)
-	s.worker.Worker <- map[uint8]interface{}{
-		1: json,
+	if err := s.worker.Submit(ctx, entity.PersistUserJob{Op: "update", Uuid: uuid, User: user, Event: event, Reverify: reverify}); err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to queue user", err)
 	}
+	s.reserve(user)
 	return nil
 }
 
-func (s *userService) Patch(uuid string, user entity.UserEntity) error {
+func (s *userService) Patch(ctx context.Context, uuid string, user entity.UserEntity) error {
+	user.CreatedAt = time.Time{}
 	if err := validateNotEmpty([2]string{"uuid", uuid}); err != nil {
 		return err
 	}
-	if user.Email == "" && user.Name == "" && user.Password == "" && user.Username == "" {
-		return errors.New("at least one data must be changed")
-	}
-	if user.Email != "" {
-		if !validateEmail(user.Email) {
-			return errors.New("invalid email address")
+	user = normalizeUser(user)
+	// only the fields given are changed, so only those are checked
+	var fields []string
+	for field, value := range map[string]string{
+		"Email":    user.Email,
+		"Name":     user.Name,
+		"Password": user.Password,
+		"Username": user.Username,
+	} {
+		if value != "" {
+			fields = append(fields, field)
 		}
 	}
-	cached, err := s.checkUuid(uuid)
+	if len(fields) == 0 {
+		return errs.Validation("at least one data must be changed")
+	}
+	if err := s.validator.Validate(user, fields...); err != nil {
+		return err
+	}
+	current, err := s.current(uuid)
 	if err != nil {
 		return err
 	}
-	if !cached {
-		b, err := sonic.Marshal(&user)
+	reverify := user.Email != "" && user.Email != current.Email
+
+	if user.Password != "" {
+		// judge the password against the user as it will be after the patch
+		patched := current
+		if user.Username != "" {
+			patched.Username = user.Username
+		}
+		if user.Email != "" {
+			patched.Email = user.Email
+		}
+		if user.Name != "" {
+			patched.Name = user.Name
+		}
+		if err := s.checkPassword(user.Password, patched); err != nil {
+			return err
+		}
+		hash, err := s.hashPassword(user.Password)
 		if err != nil {
 			return err
-		} else {
-			s.redis.SetNX(context.Background(), uuid, b, 2*time.Minute)
-			log.Printf("chacing uuid on redis [%s]\n", uuid)
 		}
+		user.Password = hash
+	}
+	user.Uuid = uuid
+	user.Verified = current.Verified && !reverify
+	event, err := s.newUserEvent(ctx, "patch", user)
+	if err != nil {
+		return err
+	}
+	if err := s.worker.Submit(ctx, entity.PersistUserJob{Op: "patch", Uuid: uuid, User: user, Event: event, Reverify: reverify}); err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed to queue user", err)
 	}
+	s.reserve(user)
+	return nil
+}
 
-	if user.Password != "" {
-		sha := sha512.New()
-		sha.Write([]byte(uuid + "." + base64.StdEncoding.EncodeToString([]byte(uuid)) + "." + user.Password))
-		user.Password = base64.RawURLEncoding.EncodeToString(sha.Sum(nil))
+func (s *userService) Authenticate(ctx context.Context, login string, password string, client entity.ClientEntity) (entity.TokenEntity, error) {
+	if login == "" || password == "" {
+		return entity.TokenEntity{}, errInvalidCredentials
 	}
-	s.worker.Wg.Add(1)
-	s.worker.Worker <- map[uint8]interface{}{
-		0: func() error {
-			return s.repository.Patch(uuid, user)
-		},
+	if strings.Contains(login, "@") {
+		login = normalizeEmail(login)
 	}
-	user.Uuid = uuid
-	data := entity.UserEventEntity{
-		Method: "patch",
-		Data:   user,
+	user, found, err := s.repository.FindByLogin(login)
+	if err != nil {
+		log.Println(err)
+		return entity.TokenEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if err := s.lockout.Check(user.Uuid, login, client.IP); err != nil {
+		return entity.TokenEntity{}, err
 	}
-	json, _ := sonic.Marshal(&data)
+	if !found {
+		dummyHash.once.Do(func() {
+			dummyHash.hash, _ = s.hasher.Hash(uuid.New().String())
+		})
+		s.hasher.Verify(dummyHash.hash, password, "")
+		s.lockout.Failed("", login, client.IP)
+		return entity.TokenEntity{}, errInvalidCredentials
+	}
+	match, err := s.verifyPassword(ctx, user, password)
 	if err != nil {
-		return err
+		return entity.TokenEntity{}, err
 	}
-	s.worker.Wg.Add(1)
-	s.worker.Worker <- map[uint8]interface{}{
-		1: json,
+	if !match {
+		s.lockout.Failed(user.Uuid, login, client.IP)
+		return entity.TokenEntity{}, errInvalidCredentials
 	}
-	return nil
+	if !user.Verified {
+		// the owner asks for another link with their email
+		return entity.TokenEntity{}, errEmailNotVerified
+	}
+	enabled, err := s.mfa.Enabled(user.Uuid)
+	if err != nil {
+		return entity.TokenEntity{}, err
+	}
+	if enabled {
+		// the failures are only forgiven once the second factor passed
+		return s.mfa.Challenge(user.Uuid)
+	}
+	s.lockout.Succeeded(user.Uuid)
+	return s.sessions.Start(user.Uuid, client)
+}
+
+func (s *userService) Get(uuid string) (entity.UserProfileEntity, error) {
+	if err := validateNotEmpty([2]string{"uuid", uuid}); err != nil {
+		return entity.UserProfileEntity{}, err
+	}
+	b, err := s.redis.Get(context.TODO(), uuid).Bytes()
+	if err == nil {
+		var user entity.UserEntity
+		if err := sonic.Unmarshal(b, &user); err == nil && user.Uuid == uuid {
+			return user.Profile(), nil
+		}
+	}
+	user, found, err := s.repository.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return entity.UserProfileEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return entity.UserProfileEntity{}, errs.NotFound("uuid not found")
+	}
+	s.cache(user)
+	return user.Profile(), nil
+}
+
+func (s *userService) GetByUsername(username string) (entity.UserProfileEntity, error) {
+	if err := validateNotEmpty([2]string{"username", username}); err != nil {
+		return entity.UserProfileEntity{}, err
+	}
+	return s.lookup(s.repository.GetByUsername(username))
+}
+
+func (s *userService) GetByEmail(email string) (entity.UserProfileEntity, error) {
+	if err := validateNotEmpty([2]string{"email", email}); err != nil {
+		return entity.UserProfileEntity{}, err
+	}
+	return s.lookup(s.repository.GetByEmail(normalizeEmail(email)))
+}
+
+func (s *userService) lookup(user entity.UserEntity, found bool, err error) (entity.UserProfileEntity, error) {
+	if err != nil {
+		log.Println(err)
+		return entity.UserProfileEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return entity.UserProfileEntity{}, errs.NotFound("user not found")
+	}
+	s.cache(user)
+	return user.Profile(), nil
+}
+
+func (s *userService) List(query entity.ListQuery) (entity.ListPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultListLimit
+	}
+	if query.Limit > maxListLimit {
+		query.Limit = maxListLimit
+	}
+	query.Key = nil
+	if query.Cursor != "" {
+		key, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return entity.ListPage{}, errs.Invalid("cursor", "cursor", "invalid cursor")
+		}
+		query.Key = &key
+	}
+	users, more, err := s.repository.List(query)
+	if err != nil {
+		log.Println(err)
+		return entity.ListPage{}, errs.Unavailable("failed connect to DB", err)
+	}
+	page := entity.ListPage{Users: make([]entity.UserProfileEntity, 0, len(users))}
+	for _, user := range users {
+		page.Users = append(page.Users, user.Profile())
+	}
+	if len(users) == 0 {
+		return page, nil
+	}
+	backward := query.Key != nil && query.Key.Backward
+	first, last := users[0], users[len(users)-1]
+	if (!backward && more) || backward {
+		page.NextCursor = encodeCursor(entity.PageKey{CreatedAt: last.CreatedAt, Uuid: last.Uuid})
+	}
+	if (backward && more) || (!backward && query.Key != nil) {
+		page.PrevCursor = encodeCursor(entity.PageKey{CreatedAt: first.CreatedAt, Uuid: first.Uuid, Backward: true})
+	}
+	return page, nil
 }
 
 // helper
 func validateNotEmpty(param ...[2]string) error {
 	var error_msg []string
+	var fields []errs.FieldError
 	for _, v := range param {
 		if v[1] == "" {
 			error_msg = append(error_msg, v[0]+" cannot be empty")
+			fields = append(fields, errs.FieldError{Field: v[0], Rule: "required", Message: v[0] + " cannot be empty"})
 		}
 	}
 	if len(error_msg) > 0 {
-		return errors.New(strings.Join(error_msg, ";"))
+		return errs.Validation(strings.Join(error_msg, ";"), fields...)
+	}
+	return nil
+}
+
+// normalizeUser trims the fields of user and brings its text into NFC, so
+// the same name is stored and measured the same way however it was typed.
+func normalizeUser(user entity.UserEntity) entity.UserEntity {
+	user.Username = strings.TrimSpace(user.Username)
+	user.Name = norm.NFC.String(strings.TrimSpace(user.Name))
+	if user.Email != "" {
+		user.Email = normalizeEmail(user.Email)
+	}
+	return user
+}
+
+// normalizeEmail lower cases the whole address, so two spellings of it
+// cannot register two accounts.
+func normalizeEmail(email string) string {
+	return strings.ToLower(norm.NFC.String(strings.TrimSpace(email)))
+}
+
+// checkPassword applies the password policy to a new password of user.
+func (s *userService) checkPassword(password string, user entity.UserEntity) error {
+	violations := s.policy.Check(password, user)
+	if len(violations) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(violations))
+	fields := make([]errs.FieldError, 0, len(violations))
+	for _, v := range violations {
+		messages = append(messages, v.Message)
+		fields = append(fields, errs.FieldError{Field: "password", Rule: v.Rule, Message: v.Message})
+	}
+	return errs.Validation(strings.Join(messages, ";"), fields...)
+}
+
+// hashPassword is the single path every mutation uses to turn a plain
+// password into its stored form.
+func (s *userService) hashPassword(password string) (string, error) {
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Println(err)
+		return "", errs.Internal("failed to hash password", err)
+	}
+	return hash, nil
+}
+
+// verifyPassword checks password against the stored hash of user. When the
+// hash was produced by an outdated scheme it is upgraded in the background.
+func (s *userService) verifyPassword(ctx context.Context, user entity.UserEntity, password string) (bool, error) {
+	match, rehash, err := s.hasher.Verify(user.Password, password, user.Uuid)
+	if err != nil {
+		log.Println(err)
+		return false, nil
+	}
+	if match && rehash {
+		hash, err := s.hasher.Hash(password)
+		if err != nil {
+			log.Println(err)
+			return match, nil
+		}
+		if err := s.worker.Submit(ctx, entity.UpdatePasswordJob{Uuid: user.Uuid, Hash: hash}); err != nil {
+			log.Println(err)
+		}
+	}
+	return match, nil
+}
+
+// persistUser stores a registered, updated or patched user with its event,
+// then refreshes the cache and nudges the outbox relay in separate jobs so
+// their failures never replay the write.
+func (s *userService) persistUser(ctx context.Context, job entity.PersistUserJob) error {
+	var err error
+	switch job.Op {
+	case "register":
+		err = s.repository.Save(job.User, job.Event)
+	case "update":
+		err = s.repository.Update(job.Uuid, job.User, job.Event)
+	case "patch":
+		err = s.repository.Patch(job.Uuid, job.User, job.Event)
+	default:
+		return fmt.Errorf("unknown persist op %q", job.Op)
+	}
+	if err != nil {
+		return err
+	}
+	if job.Op == "register" {
+		s.cache(job.User)
+	} else if err := s.worker.Submit(ctx, entity.InvalidateCacheJob{Uuid: job.Uuid}); err != nil {
+		log.Println(err)
+	}
+	if job.Op == "register" || job.Reverify {
+		if err := s.worker.Submit(ctx, entity.SendVerificationJob{Uuid: job.Uuid, Email: job.User.Email}); err != nil {
+			log.Println(err)
+		}
+	}
+	if err := s.worker.Submit(ctx, entity.PublishEventJob{}); err != nil {
+		log.Println(err)
 	}
 	return nil
 }
 
-func validateEmail(email string) bool {
-	emailRegex := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
-	return emailRegex.MatchString(email)
+func (s *userService) updatePassword(ctx context.Context, job entity.UpdatePasswordJob) error {
+	if err := s.repository.UpdatePassword(job.Uuid, job.Hash); err != nil {
+		return err
+	}
+	return s.worker.Submit(ctx, entity.InvalidateCacheJob{Uuid: job.Uuid})
+}
+
+func (s *userService) invalidateCache(ctx context.Context, job entity.InvalidateCacheJob) error {
+	return s.invalidate(job.Uuid)
+}
+
+// encodeCursor turns a page key into the opaque cursor handed to clients.
+func encodeCursor(key entity.PageKey) string {
+	b, _ := sonic.Marshal(&key)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string) (entity.PageKey, error) {
+	var key entity.PageKey
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return key, err
+	}
+	if err := sonic.Unmarshal(b, &key); err != nil {
+		return key, err
+	}
+	if key.Uuid == "" {
+		return key, errors.New("cursor has no uuid")
+	}
+	return key, nil
+}
+
+// cache stores user under its uuid, the same entry Register writes, so Get
+// can read through it.
+func (s *userService) cache(user entity.UserEntity) {
+	b, err := sonic.Marshal(&user)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	s.redis.SetNX(context.Background(), user.Uuid, b, 2*time.Minute)
+}
+
+// invalidate drops the cached copy of uuid after it changed.
+func (s *userService) invalidate(uuid string) error {
+	return s.redis.Del(context.Background(), uuid).Err()
+}
+
+// current loads the stored user uuid before a change.
+func (s *userService) current(uuid string) (entity.UserEntity, error) {
+	user, found, err := s.repository.Get(uuid)
+	if err != nil {
+		log.Println(err)
+		return entity.UserEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return entity.UserEntity{}, errs.NotFound("uuid not found")
+	}
+	return user, nil
 }
 
 func (s *userService) checkUuid(uuid string) (bool, error) {
@@ -338,9 +585,9 @@ func (s *userService) checkUuid(uuid string) (bool, error) {
 	if !exist {
 		if err != nil {
 			log.Println(err)
-			return false, errors.New("failed delete user")
+			return false, errs.Unavailable("failed delete user", err)
 		}
-		return false, errors.New("uuid not found")
+		return false, errs.NotFound("uuid not found")
 	}
 
 	return false, nil
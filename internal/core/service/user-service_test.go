@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// listRepository answers List from users and records the query it got.
+type listRepository struct {
+	port.UserRepository
+	users []entity.UserEntity
+	more  bool
+	query entity.ListQuery
+}
+
+func (r *listRepository) List(query entity.ListQuery) ([]entity.UserEntity, bool, error) {
+	r.query = query
+	return r.users, r.more, nil
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name string
+		key  entity.PageKey
+	}{
+		{"forward", entity.PageKey{CreatedAt: at, Uuid: "a"}},
+		{"backward", entity.PageKey{CreatedAt: at, Uuid: "b", Backward: true}},
+		{"zero time", entity.PageKey{Uuid: "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := decodeCursor(encodeCursor(tt.key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.CreatedAt.Equal(tt.key.CreatedAt) || key.Uuid != tt.key.Uuid || key.Backward != tt.key.Backward {
+				t.Errorf("decoded %+v, want %+v", key, tt.key)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	for _, cursor := range []string{"!!", "bm90IGpzb24", encodeCursor(entity.PageKey{CreatedAt: time.Now()})} {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded", cursor)
+		}
+	}
+}
+
+func TestListBounds(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	first := entity.UserEntity{Uuid: "u1", CreatedAt: after.Add(time.Hour)}
+	last := entity.UserEntity{Uuid: "u2", CreatedAt: after.Add(2 * time.Hour)}
+	forward := encodeCursor(entity.PageKey{CreatedAt: after.Add(time.Minute), Uuid: "u0"})
+	backward := encodeCursor(entity.PageKey{CreatedAt: before, Uuid: "u3", Backward: true})
+
+	tests := []struct {
+		name   string
+		cursor string
+		limit  int
+		more   bool
+		key    *entity.PageKey
+		limitQ int
+		next   bool
+		prev   bool
+	}{
+		{"first page", "", 2, true, nil, 2, true, false},
+		{"only page", "", 0, false, nil, defaultListLimit, false, false},
+		{"middle page", forward, 2, true, &entity.PageKey{CreatedAt: after.Add(time.Minute), Uuid: "u0"}, 2, true, true},
+		{"last page", forward, maxListLimit + 1, false, &entity.PageKey{CreatedAt: after.Add(time.Minute), Uuid: "u0"}, maxListLimit, false, true},
+		{"backward with more", backward, 2, true, &entity.PageKey{CreatedAt: before, Uuid: "u3", Backward: true}, 2, true, true},
+		{"backward to start", backward, 2, false, &entity.PageKey{CreatedAt: before, Uuid: "u3", Backward: true}, 2, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &listRepository{users: []entity.UserEntity{first, last}, more: tt.more}
+			s := &userService{repository: repo}
+			page, err := s.List(entity.ListQuery{Cursor: tt.cursor, Limit: tt.limit, CreatedAfter: after, CreatedBefore: before})
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := repo.query
+			if !q.CreatedAfter.Equal(after) || !q.CreatedBefore.Equal(before) {
+				t.Errorf("bounds = [%v, %v), want [%v, %v)", q.CreatedAfter, q.CreatedBefore, after, before)
+			}
+			if q.Limit != tt.limitQ {
+				t.Errorf("limit = %d, want %d", q.Limit, tt.limitQ)
+			}
+			if (q.Key == nil) != (tt.key == nil) || q.Key != nil && (!q.Key.CreatedAt.Equal(tt.key.CreatedAt) || q.Key.Uuid != tt.key.Uuid || q.Key.Backward != tt.key.Backward) {
+				t.Errorf("key = %+v, want %+v", q.Key, tt.key)
+			}
+			if (page.NextCursor != "") != tt.next || (page.PrevCursor != "") != tt.prev {
+				t.Fatalf("cursors next=%q prev=%q, want next=%v prev=%v", page.NextCursor, page.PrevCursor, tt.next, tt.prev)
+			}
+			if tt.next {
+				key, _ := decodeCursor(page.NextCursor)
+				if key.Uuid != last.Uuid || !key.CreatedAt.Equal(last.CreatedAt) || key.Backward {
+					t.Errorf("next cursor = %+v, want after %s", key, last.Uuid)
+				}
+			}
+			if tt.prev {
+				key, _ := decodeCursor(page.PrevCursor)
+				if key.Uuid != first.Uuid || !key.CreatedAt.Equal(first.CreatedAt) || !key.Backward {
+					t.Errorf("prev cursor = %+v, want before %s", key, first.Uuid)
+				}
+			}
+		})
+	}
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	s := &userService{repository: &listRepository{}}
+	if _, err := s.List(entity.ListQuery{Cursor: "not a cursor"}); !errors.Is(err, errs.ErrValidation) {
+		t.Errorf("err = %v, want a validation error", err)
+	}
+}
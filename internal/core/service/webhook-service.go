@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+const webhookBatch = 20
+
+// webhookEventTypes are the user events webhooks can subscribe to.
+var webhookEventTypes = map[string]bool{
+	userEventTypes["register"]: true,
+	userEventTypes["update"]:   true,
+	userEventTypes["patch"]:    true,
+	userEventTypes["delete"]:   true,
+}
+
+type webhookService struct {
+	repository   port.WebhookRepository
+	sender       port.WebhookSender
+	box          port.SecretBox
+	validator    port.Validator
+	maxAttempts  int
+	disableAfter int
+	allowHTTP    bool
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	lease        time.Duration
+}
+
+// NewWebhookService delivers user events to the webhooks subscribed to
+// them. A delivery gets WEBHOOK_MAX_ATTEMPTS attempts (default 8) with
+// jittered exponential backoff from 10s up to 1h, and a webhook is disabled
+// once WEBHOOK_DISABLE_AFTER deliveries in a row failed (default 5).
+// Webhook URLs must be https unless WEBHOOK_ALLOW_HTTP is true. Secrets are
+// sealed by box.
+func NewWebhookService(repository port.WebhookRepository, sender port.WebhookSender, box port.SecretBox, validator port.Validator) port.WebhookService {
+	return &webhookService{
+		repository:   repository,
+		sender:       sender,
+		box:          box,
+		validator:    validator,
+		maxAttempts:  envPositive("WEBHOOK_MAX_ATTEMPTS", 8),
+		disableAfter: envPositive("WEBHOOK_DISABLE_AFTER", 5),
+		allowHTTP:    os.Getenv("WEBHOOK_ALLOW_HTTP") == "true",
+		baseDelay:    10 * time.Second,
+		maxDelay:     time.Hour,
+		lease:        5 * time.Minute,
+	}
+}
+
+func (w *webhookService) Create(request entity.WebhookRequestEntity) (entity.WebhookEntity, error) {
+	if err := w.check(request); err != nil {
+		return entity.WebhookEntity{}, err
+	}
+	secret := request.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return entity.WebhookEntity{}, errs.Internal("failed to generate secret", err)
+		}
+		secret = "whsec_" + base64.RawURLEncoding.EncodeToString(b)
+	}
+	sealed, err := w.box.Seal([]byte(secret))
+	if err != nil {
+		return entity.WebhookEntity{}, errs.Internal("failed to seal secret", err)
+	}
+	webhook := entity.WebhookEntity{
+		ID:           uuid.New().String(),
+		URL:          request.URL,
+		Events:       request.Events,
+		SealedSecret: sealed,
+		Enabled:      request.Enabled == nil || *request.Enabled,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if !webhook.Enabled {
+		webhook.DisabledAt = &webhook.CreatedAt
+	}
+	if err := w.repository.Save(webhook); err != nil {
+		log.Println(err)
+		return entity.WebhookEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if request.Secret == "" {
+		webhook.Secret = secret
+	}
+	return webhook, nil
+}
+
+func (w *webhookService) List() ([]entity.WebhookEntity, error) {
+	webhooks, err := w.repository.List()
+	if err != nil {
+		log.Println(err)
+		return nil, errs.Unavailable("failed connect to DB", err)
+	}
+	return webhooks, nil
+}
+
+func (w *webhookService) Get(id string) (entity.WebhookEntity, error) {
+	webhook, found, err := w.repository.Get(id)
+	if err != nil {
+		log.Println(err)
+		return entity.WebhookEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return entity.WebhookEntity{}, errs.NotFound("webhook not found")
+	}
+	return webhook, nil
+}
+
+func (w *webhookService) Update(id string, request entity.WebhookRequestEntity) (entity.WebhookEntity, error) {
+	if err := w.check(request); err != nil {
+		return entity.WebhookEntity{}, err
+	}
+	webhook, err := w.Get(id)
+	if err != nil {
+		return entity.WebhookEntity{}, err
+	}
+	webhook.URL = request.URL
+	webhook.Events = request.Events
+	if request.Secret != "" {
+		webhook.SealedSecret, err = w.box.Seal([]byte(request.Secret))
+		if err != nil {
+			return entity.WebhookEntity{}, errs.Internal("failed to seal secret", err)
+		}
+	}
+	webhook.UpdatedAt = time.Now()
+	if request.Enabled != nil && *request.Enabled != webhook.Enabled {
+		webhook.Enabled = *request.Enabled
+		webhook.DisabledAt = nil
+		if webhook.Enabled {
+			// a webhook enabled again gets a fresh budget of failures
+			webhook.Failures = 0
+		} else {
+			webhook.DisabledAt = &webhook.UpdatedAt
+		}
+	}
+	if err := w.repository.Save(webhook); err != nil {
+		log.Println(err)
+		return entity.WebhookEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	return webhook, nil
+}
+
+func (w *webhookService) Delete(id string) error {
+	deleted, err := w.repository.Delete(id)
+	if err != nil {
+		log.Println(err)
+		return errs.Unavailable("failed connect to DB", err)
+	}
+	if !deleted {
+		return errs.NotFound("webhook not found")
+	}
+	return nil
+}
+
+func (w *webhookService) Deliveries(id string, status string, limit int) ([]entity.WebhookDeliveryEntity, error) {
+	switch status {
+	case "", entity.DeliveryPending, entity.DeliverySucceeded, entity.DeliveryFailed:
+	default:
+		return nil, errs.Invalid("status", "oneof", "status must be pending, succeeded or failed")
+	}
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+	if _, err := w.Get(id); err != nil {
+		return nil, err
+	}
+	deliveries, err := w.repository.Deliveries(id, status, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, errs.Unavailable("failed connect to DB", err)
+	}
+	return deliveries, nil
+}
+
+func (w *webhookService) Delivery(id string, deliveryID string) (entity.WebhookDeliveryEntity, error) {
+	delivery, found, err := w.repository.Delivery(id, deliveryID)
+	if err != nil {
+		log.Println(err)
+		return entity.WebhookDeliveryEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return entity.WebhookDeliveryEntity{}, errs.NotFound("delivery not found")
+	}
+	return delivery, nil
+}
+
+func (w *webhookService) Replay(id string, deliveryID string) (entity.WebhookDeliveryEntity, error) {
+	webhook, err := w.Get(id)
+	if err != nil {
+		return entity.WebhookDeliveryEntity{}, err
+	}
+	if !webhook.Enabled {
+		return entity.WebhookDeliveryEntity{}, errs.FailedPrecondition("webhook is disabled")
+	}
+	delivery, found, err := w.repository.Replay(id, deliveryID)
+	if err != nil {
+		log.Println(err)
+		return entity.WebhookDeliveryEntity{}, errs.Unavailable("failed connect to DB", err)
+	}
+	if !found {
+		return entity.WebhookDeliveryEntity{}, errs.NotFound("delivery not found")
+	}
+	return delivery, nil
+}
+
+// check validates request, its URL scheme and its event filter.
+func (w *webhookService) check(request entity.WebhookRequestEntity) error {
+	if err := w.validator.Validate(request); err != nil {
+		return err
+	}
+	u, err := url.Parse(request.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && !(w.allowHTTP && u.Scheme == "http")) {
+		return errs.Invalid("url", "scheme", "url must be an absolute https URL")
+	}
+	for _, typ := range request.Events {
+		if !webhookEventTypes[typ] {
+			return errs.Invalid("events", "oneof", "unknown event type "+typ)
+		}
+	}
+	return nil
+}
+
+// Publish queues a delivery of event to every enabled webhook subscribed to
+// its type. The relay may publish an event twice; the second is skipped.
+func (w *webhookService) Publish(ctx context.Context, event entity.OutboxEntity) error {
+	if len(event.Headers) == 0 {
+		return nil
+	}
+	var headers map[string]string
+	if err := sonic.Unmarshal(event.Headers, &headers); err != nil {
+		return err
+	}
+	typ := headers["ce_type"]
+	if !webhookEventTypes[typ] {
+		return nil
+	}
+	webhooks, err := w.repository.Enabled()
+	if err != nil {
+		return err
+	}
+	var body []byte
+	var deliveries []entity.WebhookDeliveryEntity
+	for _, webhook := range webhooks {
+		if !subscribed(webhook, typ) {
+			continue
+		}
+		if body == nil {
+			if body, err = structuredEvent(headers, event); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, entity.WebhookDeliveryEntity{
+			ID:            uuid.New().String(),
+			WebhookID:     webhook.ID,
+			EventID:       headers["ce_id"],
+			EventType:     typ,
+			Payload:       body,
+			Status:        entity.DeliveryPending,
+			NextAttemptAt: time.Now(),
+			CreatedAt:     time.Now(),
+		})
+	}
+	return w.repository.Enqueue(deliveries)
+}
+
+func (w *webhookService) Close() error {
+	return nil
+}
+
+func subscribed(webhook entity.WebhookEntity, typ string) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, event := range webhook.Events {
+		if event == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *webhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		deliveries, err := w.repository.Claim(webhookBatch, w.lease)
+		if err != nil {
+			log.Println("webhook deliveries:", err)
+			continue
+		}
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery entity.WebhookDeliveryEntity) {
+				defer wg.Done()
+				w.deliver(ctx, delivery)
+			}(delivery)
+		}
+		wg.Wait()
+	}
+}
+
+// deliver makes one attempt at delivery and records its outcome.
+func (w *webhookService) deliver(ctx context.Context, delivery entity.WebhookDeliveryEntity) {
+	webhook, found, err := w.repository.Get(delivery.WebhookID)
+	if err != nil || !found {
+		// the lease brings it back if the webhook is still there
+		log.Println("webhook delivery", delivery.ID, err)
+		return
+	}
+	attempt := entity.WebhookAttemptEntity{DeliveryID: delivery.ID, At: time.Now()}
+	secret, err := w.box.Open(webhook.SealedSecret)
+	if err == nil {
+		timestamp := strconv.FormatInt(attempt.At.Unix(), 10)
+		attempt.StatusCode, err = w.sender.Send(ctx, webhook.URL, map[string]string{
+			"Content-Type":      "application/cloudevents+json",
+			"User-Agent":        "koalachat-webhooks",
+			"Webhook-Id":        delivery.ID,
+			"Webhook-Timestamp": timestamp,
+			"Webhook-Signature": "v1=" + sign(secret, delivery.ID, timestamp, delivery.Payload),
+		}, delivery.Payload)
+	}
+	attempt.DurationMs = time.Since(attempt.At).Milliseconds()
+	if err == nil && (attempt.StatusCode < 200 || attempt.StatusCode > 299) {
+		err = fmt.Errorf("endpoint answered %d", attempt.StatusCode)
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	delivery.Attempts++
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+	switch {
+	case err == nil:
+		delivery.Status = entity.DeliverySucceeded
+		delivery.DeliveredAt = &attempt.At
+	case delivery.Attempts >= w.maxAttempts:
+		delivery.Status = entity.DeliveryFailed
+	default:
+		delivery.NextAttemptAt = time.Now().Add(backoff(delivery.Attempts, w.baseDelay, w.maxDelay))
+	}
+	disabled, saveErr := w.repository.Attempted(delivery, attempt, w.disableAfter)
+	if saveErr != nil {
+		log.Println(saveErr)
+		return
+	}
+	if err != nil {
+		log.Printf("webhook delivery %s to %s failed, attempt %d: %v\n", delivery.ID, webhook.URL, delivery.Attempts, err)
+	}
+	if disabled {
+		log.Printf("webhook %s disabled after %d failed deliveries\n", webhook.ID, w.disableAfter)
+	}
+}
+
+// sign returns the hex HMAC-SHA256 under secret of the delivery id, the
+// unix timestamp and the body, joined by dots. Receivers recompute it and
+// reject old timestamps to stop replays.
+func sign(secret []byte, id string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
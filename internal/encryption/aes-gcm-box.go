@@ -0,0 +1,59 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+var ErrCiphertext = errors.New("ciphertext too short")
+
+type aesGcmBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox encrypts with AES-256-GCM under the key in the keyEnv
+// variable, 32 bytes in standard base64. Each kind of secret has its own
+// key, so one can be rotated or leaked without the others.
+func NewSecretBox(keyEnv string) (port.SecretBox, error) {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv(keyEnv))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be a base64 encoded 32 byte key", keyEnv)
+	}
+	return NewAesGcmBox(key)
+}
+
+func NewAesGcmBox(key []byte) (port.SecretBox, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGcmBox{aead: aead}, nil
+}
+
+// Seal returns nonce || ciphertext.
+func (b *aesGcmBox) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (b *aesGcmBox) Open(ciphertext []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, ErrCiphertext
+	}
+	return b.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
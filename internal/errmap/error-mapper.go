@@ -0,0 +1,101 @@
+package errmap
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/koalachatapp/user/internal/core/errs"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ContentType is the media type of a Problem body.
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Type is always
+// about:blank, so Title is the reason phrase of Status.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   []errs.FieldError `json:"errors,omitempty"`
+}
+
+type mapping struct {
+	status int
+	code   codes.Code
+}
+
+// mappings is the one place a kind of domain error gets its meaning on
+// the wire.
+var mappings = map[errs.Kind]mapping{
+	errs.KindInternal:           {http.StatusInternalServerError, codes.Internal},
+	errs.KindValidation:         {http.StatusBadRequest, codes.InvalidArgument},
+	errs.KindUnauthorized:       {http.StatusUnauthorized, codes.Unauthenticated},
+	errs.KindForbidden:          {http.StatusForbidden, codes.PermissionDenied},
+	errs.KindNotFound:           {http.StatusNotFound, codes.NotFound},
+	errs.KindConflict:           {http.StatusConflict, codes.AlreadyExists},
+	errs.KindFailedPrecondition: {http.StatusConflict, codes.FailedPrecondition},
+	errs.KindTooManyRequests:    {http.StatusTooManyRequests, codes.ResourceExhausted},
+	errs.KindUnavailable:        {http.StatusServiceUnavailable, codes.Unavailable},
+}
+
+func lookup(err error) (*errs.Error, mapping) {
+	e := errs.As(err)
+	if e.Kind == errs.KindInternal {
+		log.Println(err)
+	}
+	m, ok := mappings[e.Kind]
+	if !ok {
+		m = mappings[errs.KindInternal]
+	}
+	return e, m
+}
+
+// HTTP maps err onto the problem answering the request for instance.
+func HTTP(err error, instance string) Problem {
+	e, m := lookup(err)
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(m.status),
+		Status:   m.status,
+		Detail:   e.Message,
+		Instance: instance,
+		Errors:   e.Fields,
+	}
+}
+
+// RetryAfter is how long a rate limited caller has to wait, or 0.
+func RetryAfter(err error) int {
+	e := errs.As(err)
+	if e.Kind != errs.KindTooManyRequests || e.RetryAfter <= 0 {
+		return 0
+	}
+	return int(e.RetryAfter.Seconds()) + 1
+}
+
+// GRPC maps err onto a status error, attaching the broken fields as
+// BadRequest and the wait as RetryInfo.
+func GRPC(err error) error {
+	e, m := lookup(err)
+	st := status.New(m.code, e.Message)
+	switch {
+	case len(e.Fields) > 0:
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+			st = detailed
+		}
+	case e.RetryAfter > 0:
+		if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)}); err == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
@@ -0,0 +1,39 @@
+package errmap
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/koalachatapp/user/internal/core/errs"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   codes.Code
+		detail string
+	}{
+		{"validation", errs.Invalid("email", "email", "invalid email"), http.StatusBadRequest, codes.InvalidArgument, "invalid email"},
+		{"taken", errs.Conflict("user already registered"), http.StatusConflict, codes.AlreadyExists, "user already registered"},
+		{"wrong state", errs.FailedPrecondition("email already verified"), http.StatusConflict, codes.FailedPrecondition, "email already verified"},
+		{"forbidden", errs.Forbidden("email not verified"), http.StatusForbidden, codes.PermissionDenied, "email not verified"},
+		{"foreign error", errors.New("pq: secret detail"), http.StatusInternalServerError, codes.Internal, "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := HTTP(tt.err, "/x")
+			if problem.Status != tt.status || problem.Detail != tt.detail {
+				t.Errorf("HTTP = %d %q, want %d %q", problem.Status, problem.Detail, tt.status, tt.detail)
+			}
+			st := status.Convert(GRPC(tt.err))
+			if st.Code() != tt.code || st.Message() != tt.detail {
+				t.Errorf("GRPC = %s %q, want %s %q", st.Code(), st.Message(), tt.code, tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package hasher
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const defaultBcryptCost = 12
+
+type bcryptScheme struct {
+	cost int
+}
+
+func (b *bcryptScheme) name() string {
+	return "bcrypt"
+}
+
+func (b *bcryptScheme) owns(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// hash encodes in the bcrypt modular crypt format $2a$<cost>$<salt+key>, which
+// already records the algorithm revision and cost.
+func (b *bcryptScheme) hash(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+func (b *bcryptScheme) verify(encoded string, password string, _ string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *bcryptScheme) outdated(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.cost
+}
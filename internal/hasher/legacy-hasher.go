@@ -0,0 +1,45 @@
+package hasher
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// legacySha512Scheme verifies the unprefixed base64url SHA-512 hashes written
+// before the hasher existed. Register salted with the uuid alone (its reversed
+// uuid was always empty) while Update and Patch salted with base64(uuid), so
+// both variants are tried. It never produces new hashes.
+type legacySha512Scheme struct{}
+
+func (l *legacySha512Scheme) name() string {
+	return "sha512-legacy"
+}
+
+func (l *legacySha512Scheme) owns(encoded string) bool {
+	return encoded != "" && !strings.HasPrefix(encoded, "$")
+}
+
+func (l *legacySha512Scheme) hash(string) (string, error) {
+	return "", errors.New("legacy sha512 hashes can only be verified")
+}
+
+func (l *legacySha512Scheme) verify(encoded string, password string, uuid string) (bool, error) {
+	want, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false, err
+	}
+	for _, salt := range []string{"", base64.StdEncoding.EncodeToString([]byte(uuid))} {
+		sum := sha512.Sum512([]byte(uuid + "." + salt + "." + password))
+		if subtle.ConstantTimeCompare(want, sum[:]) == 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (l *legacySha512Scheme) outdated(string) bool {
+	return true
+}
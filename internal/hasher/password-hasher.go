@@ -0,0 +1,106 @@
+package hasher
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+var ErrUnknownScheme = errors.New("unknown password hash scheme")
+
+// scheme is a single password hashing algorithm.
+type scheme interface {
+	// name is the algorithm identifier recorded in the encoded hash.
+	name() string
+	// owns reports whether encoded was produced by this scheme.
+	owns(encoded string) bool
+	hash(password string) (string, error)
+	verify(encoded string, password string, uuid string) (bool, error)
+	// outdated reports whether encoded uses weaker parameters than the
+	// scheme is currently configured with.
+	outdated(encoded string) bool
+}
+
+type passwordHasher struct {
+	current scheme
+	schemes []scheme
+}
+
+// NewPasswordHasher builds the hasher from the environment. PASSWORD_HASHER
+// selects the scheme for new hashes (argon2id or bcrypt, default argon2id);
+// every known scheme, including the legacy SHA-512 one, stays verifiable.
+func NewPasswordHasher() port.PasswordHasher {
+	switch os.Getenv("PASSWORD_HASHER") {
+	case "bcrypt":
+		return NewBcryptHasher(envInt("BCRYPT_COST", defaultBcryptCost))
+	case "", "argon2id":
+	default:
+		log.SetPrefix("[Warning] ")
+		log.Printf("unknown PASSWORD_HASHER %q, using argon2id\n", os.Getenv("PASSWORD_HASHER"))
+	}
+	return NewArgon2idHasher(Argon2idParams{
+		Memory:  uint32(envInt("ARGON2_MEMORY", int(defaultArgon2idParams.Memory))),
+		Time:    uint32(envInt("ARGON2_TIME", int(defaultArgon2idParams.Time))),
+		Threads: uint8(envInt("ARGON2_THREADS", int(defaultArgon2idParams.Threads))),
+	})
+}
+
+// NewArgon2idHasher hashes new passwords with argon2id.
+func NewArgon2idHasher(params Argon2idParams) port.PasswordHasher {
+	return newPasswordHasher(&argon2idScheme{params: params})
+}
+
+// NewBcryptHasher hashes new passwords with bcrypt at the given cost.
+func NewBcryptHasher(cost int) port.PasswordHasher {
+	return newPasswordHasher(&bcryptScheme{cost: cost})
+}
+
+func newPasswordHasher(current scheme) *passwordHasher {
+	h := &passwordHasher{current: current}
+	h.schemes = append(h.schemes, current)
+	for _, s := range []scheme{
+		&argon2idScheme{params: defaultArgon2idParams},
+		&bcryptScheme{cost: defaultBcryptCost},
+		&legacySha512Scheme{},
+	} {
+		if s.name() != current.name() {
+			h.schemes = append(h.schemes, s)
+		}
+	}
+	return h
+}
+
+func (h *passwordHasher) Hash(password string) (string, error) {
+	return h.current.hash(password)
+}
+
+func (h *passwordHasher) Verify(encoded string, password string, uuid string) (bool, bool, error) {
+	for _, s := range h.schemes {
+		if !s.owns(encoded) {
+			continue
+		}
+		match, err := s.verify(encoded, password, uuid)
+		if err != nil || !match {
+			return false, false, err
+		}
+		return true, s != h.current || s.outdated(encoded), nil
+	}
+	return false, false, ErrUnknownScheme
+}
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		log.SetPrefix("[Warning] ")
+		log.Printf("invalid %s %q, using %d\n", key, v, def)
+		return def
+	}
+	return i
+}
@@ -0,0 +1,104 @@
+package hasher
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+const testUuid = "5f0c6b8e-8d7a-4a53-9d3c-2b1f0e6a7c41"
+
+var testParams = Argon2idParams{Memory: 1024, Time: 1, Threads: 1}
+
+// legacyHash builds a hash the way the service wrote them before the hasher
+// existed.
+func legacyHash(uuid string, salt string, password string) string {
+	sum := sha512.Sum512([]byte(uuid + "." + salt + "." + password))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifyLegacySha512(t *testing.T) {
+	hasher := NewArgon2idHasher(testParams)
+	registered := legacyHash(testUuid, "", "correct horse")
+	updated := legacyHash(testUuid, base64.StdEncoding.EncodeToString([]byte(testUuid)), "correct horse")
+
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		uuid     string
+		match    bool
+		rehash   bool
+		err      error
+	}{
+		{"register salt", registered, "correct horse", testUuid, true, true, nil},
+		{"update salt", updated, "correct horse", testUuid, true, true, nil},
+		{"wrong password", registered, "correct horse!", testUuid, false, false, nil},
+		{"wrong uuid", registered, "correct horse", "00000000-0000-0000-0000-000000000000", false, false, nil},
+		{"empty password", registered, "", testUuid, false, false, nil},
+		{"unknown scheme", "$md5$abc", "correct horse", testUuid, false, false, ErrUnknownScheme},
+		{"empty hash", "", "correct horse", testUuid, false, false, ErrUnknownScheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := hasher.Verify(tt.encoded, tt.password, tt.uuid)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if match != tt.match || rehash != tt.rehash {
+				t.Errorf("Verify = (%v, %v), want (%v, %v)", match, rehash, tt.match, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestVerifyRehash(t *testing.T) {
+	current := NewArgon2idHasher(testParams)
+	weaker, err := NewArgon2idHasher(Argon2idParams{Memory: 512, Time: 1, Threads: 1}).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := current.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcrypt, err := NewBcryptHasher(4).Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		rehash  bool
+	}{
+		{"current params", fresh, false},
+		{"weaker params", weaker, true},
+		{"other scheme", bcrypt, true},
+		{"legacy", legacyHash(testUuid, "", "correct horse"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash, err := current.Verify(tt.encoded, "correct horse", testUuid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !match {
+				t.Fatal("password did not match")
+			}
+			if rehash != tt.rehash {
+				t.Errorf("rehash = %v, want %v", rehash, tt.rehash)
+			}
+			if match, rehash, _ := current.Verify(tt.encoded, "wrong horse", testUuid); match || rehash {
+				t.Errorf("wrong password: Verify = (%v, %v), want (false, false)", match, rehash)
+			}
+		})
+	}
+}
+
+func TestLegacyNeverHashes(t *testing.T) {
+	if _, err := (&legacySha512Scheme{}).hash("correct horse"); err == nil {
+		t.Error("legacy scheme produced a hash")
+	}
+}
@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type logMailer struct {
+	from string
+	dir  string
+}
+
+// NewLogMailer writes every mail to a .eml file in dir, or to the log when
+// dir is empty. It never delivers anything.
+func NewLogMailer(from string, dir string) port.Mailer {
+	return &logMailer{
+		from: from,
+		dir:  dir,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, mail entity.MailEntity) error {
+	b := message(m.from, mail)
+	if m.dir == "" {
+		log.Printf("mail to %s:\n%s\n", mail.To, b)
+		return nil
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".eml"
+	return os.WriteFile(filepath.Join(m.dir, name), b, 0o600)
+}
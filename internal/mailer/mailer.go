@@ -0,0 +1,27 @@
+package mailer
+
+import (
+	"log"
+	"os"
+
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// NewMailer returns the mailer selected by MAILER: "smtp" sends through
+// SMTP_HOST, anything else writes mails to MAIL_DIR or the log for local
+// development.
+func NewMailer() port.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@koala.chat"
+	}
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		return NewSmtpMailer(from)
+	case "", "log", "file":
+	default:
+		log.SetPrefix("[Warning] ")
+		log.Println("unknown MAILER " + os.Getenv("MAILER") + ", falling back to log")
+	}
+	return NewLogMailer(from, os.Getenv("MAIL_DIR"))
+}
@@ -0,0 +1,70 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type smtpMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewSmtpMailer sends through SMTP_HOST:SMTP_PORT (default port 587),
+// upgrading to TLS when the server offers STARTTLS. SMTP_USERNAME and
+// SMTP_PASSWORD enable PLAIN auth.
+func NewSmtpMailer(from string) port.Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	smtpPort := os.Getenv("SMTP_PORT")
+	if smtpPort == "" {
+		smtpPort = "587"
+	}
+	var auth smtp.Auth
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return &smtpMailer{
+		addr: net.JoinHostPort(host, smtpPort),
+		host: host,
+		auth: auth,
+		from: from,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, mail entity.MailEntity) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, m.auth, m.from, []string{mail.To}, message(m.from, mail))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message renders mail as a plain text RFC 5322 message.
+func message(from string, mail entity.MailEntity) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", "")
+	var b strings.Builder
+	b.WriteString("From: " + clean.Replace(from) + "\r\n")
+	b.WriteString("To: " + clean.Replace(mail.To) + "\r\n")
+	b.WriteString("Subject: " + clean.Replace(mail.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
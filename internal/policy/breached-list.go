@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BreachedList looks passwords up in a local copy of a breached password
+// corpus in the k-anonymity range format: one file per 5 character SHA-1
+// prefix, named <PREFIX> or <PREFIX>.txt, with one SUFFIX:COUNT line per
+// hash. A lookup only reads the file of the password's prefix.
+type BreachedList struct {
+	dir string
+}
+
+func NewBreachedList(dir string) *BreachedList {
+	return &BreachedList{dir: dir}
+}
+
+func (b *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	f, err := os.Open(filepath.Join(b.dir, hash[:5]))
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = os.Open(filepath.Join(b.dir, hash[:5]+".txt"))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		// no breached hash has this prefix
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(suffix, hash[5:]) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
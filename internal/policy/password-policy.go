@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"github.com/nbutton23/zxcvbn-go"
+)
+
+type passwordPolicy struct {
+	minLength int
+	maxLength int
+	minScore  int
+	breached  *BreachedList
+}
+
+// NewPasswordPolicy reads PASSWORD_MIN_LENGTH (default 8),
+// PASSWORD_MAX_LENGTH (default 128), PASSWORD_MIN_SCORE, the zxcvbn score
+// from 0 to 4 a password needs (default 3), and BREACHED_PASSWORDS_DIR, see
+// NewBreachedList.
+func NewPasswordPolicy() port.PasswordPolicy {
+	p := &passwordPolicy{
+		minLength: envInt("PASSWORD_MIN_LENGTH", 8),
+		maxLength: envInt("PASSWORD_MAX_LENGTH", 128),
+		minScore:  envInt("PASSWORD_MIN_SCORE", 3),
+	}
+	if dir := os.Getenv("BREACHED_PASSWORDS_DIR"); dir != "" {
+		p.breached = NewBreachedList(dir)
+	}
+	return p
+}
+
+func (p *passwordPolicy) Check(password string, user entity.UserEntity) []entity.PolicyViolationEntity {
+	var violations []entity.PolicyViolationEntity
+	violate := func(rule string, message string) {
+		violations = append(violations, entity.PolicyViolationEntity{Rule: rule, Message: message})
+	}
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		violate("min_length", "password must be at least "+strconv.Itoa(p.minLength)+" characters")
+	}
+	if length > p.maxLength {
+		violate("max_length", "password must be at most "+strconv.Itoa(p.maxLength)+" characters")
+	}
+	lower := strings.ToLower(password)
+	if len(user.Username) >= 3 && strings.Contains(lower, strings.ToLower(user.Username)) {
+		violate("contains_username", "password must not contain the username")
+	}
+	if local, _, _ := strings.Cut(strings.ToLower(user.Email), "@"); len(local) >= 3 && strings.Contains(lower, local) {
+		violate("contains_email", "password must not contain the email address")
+	}
+	if length <= p.maxLength {
+		// zxcvbn is quadratic in the length, only score passwords we accept
+		inputs := []string{user.Username, user.Email, user.Name}
+		if result := zxcvbn.PasswordStrength(password, inputs); result.Score < p.minScore {
+			violate("strength", "password is too easy to guess")
+		}
+	}
+	if p.breached != nil {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			log.Println(err)
+		} else if breached {
+			violate("breached", "password appeared in a data breach")
+		}
+	}
+	return violations
+}
+
+func envInt(key string, def int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || i < 0 {
+		return def
+	}
+	return i
+}
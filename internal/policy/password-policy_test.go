@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+// breachedDir writes a range file listing passwords, the way the corpus
+// is laid out, and returns its directory.
+func breachedDir(t *testing.T, passwords ...string) string {
+	dir := t.TempDir()
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+		f, err := os.OpenFile(filepath.Join(dir, hash[:5]+".txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(hash[5:] + ":42\r\n")
+		f.Close()
+	}
+	return dir
+}
+
+func TestCheck(t *testing.T) {
+	user := entity.UserEntity{Username: "koala", Email: "marsupial@example.com", Name: "Eucalyptus Fan"}
+	policy := &passwordPolicy{
+		minLength: 8,
+		maxLength: 64,
+		minScore:  3,
+		breached:  NewBreachedList(breachedDir(t, "Tr0ub4dor&3xyz!")),
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		rules    []string
+	}{
+		{"strong", "violet-harbor-quantum-47", nil},
+		{"too short", "aB3$", []string{"min_length", "strength"}},
+		{"length in runes", "ÄÖÜäöüßé", nil},
+		{"too long", strings.Repeat("x9!Q", 17), []string{"max_length"}},
+		{"contains username", "KOALA-violet-harbor-47", []string{"contains_username"}},
+		{"contains email", "violet-marsupial-harbor-47", []string{"contains_email"}},
+		{"weak", "password1", []string{"strength"}},
+		{"breached", "Tr0ub4dor&3xyz!", []string{"breached"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, v := range policy.Check(tt.password, user) {
+				if v.Message == "" {
+					t.Errorf("rule %s has no message", v.Rule)
+				}
+				rules = append(rules, v.Rule)
+			}
+			sort.Strings(rules)
+			want := append([]string(nil), tt.rules...)
+			sort.Strings(want)
+			if strings.Join(rules, ",") != strings.Join(want, ",") {
+				t.Errorf("Check(%q) = %v, want %v", tt.password, rules, want)
+			}
+		})
+	}
+}
+
+func TestCheckShortIdentity(t *testing.T) {
+	// names under three characters are too common to forbid
+	policy := &passwordPolicy{minLength: 8, maxLength: 64, minScore: 0}
+	user := entity.UserEntity{Username: "ab", Email: "cd@example.com"}
+	if violations := policy.Check("xxabxxcdxx", user); len(violations) != 0 {
+		t.Errorf("Check = %v, want no violations", violations)
+	}
+}
+
+func TestBreachedListMissingRange(t *testing.T) {
+	breached, err := NewBreachedList(t.TempDir()).Contains("anything")
+	if err != nil || breached {
+		t.Errorf("Contains = (%v, %v), want (false, nil)", breached, err)
+	}
+}
@@ -0,0 +1,37 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type fanoutPublisher struct {
+	publishers []port.EventPublisher
+}
+
+// NewFanoutPublisher publishes every event to each of publishers in turn,
+// stopping at the first that fails. The relay then publishes the event to
+// all of them again, so every one must put up with duplicates.
+func NewFanoutPublisher(publishers ...port.EventPublisher) port.EventPublisher {
+	return &fanoutPublisher{publishers: publishers}
+}
+
+func (p *fanoutPublisher) Publish(ctx context.Context, event entity.OutboxEntity) error {
+	for _, publisher := range p.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *fanoutPublisher) Close() error {
+	var errs []error
+	for _, publisher := range p.publishers {
+		errs = append(errs, publisher.Close())
+	}
+	return errors.Join(errs...)
+}
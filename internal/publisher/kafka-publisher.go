@@ -0,0 +1,74 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/Shopify/sarama"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type kafkaPublisher struct {
+	producer sarama.SyncProducer
+}
+
+// NewKafkaPublisher publishes to the broker at KAFKA_URL (default
+// kafka:9092), keying each message by the event key. A broker that cannot
+// be reached is logged and every publish fails until restart.
+func NewKafkaPublisher() port.EventPublisher {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	// idempotence keeps retries from duplicating or reordering the events
+	// of a partition; it needs acks from all replicas and one request in
+	// flight per broker
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 10
+	config.Net.MaxOpenRequests = 1
+	addr := os.Getenv("KAFKA_URL")
+	if addr == "" {
+		addr = "kafka:9092"
+	}
+	producer, err := sarama.NewSyncProducer([]string{addr}, config)
+	if err != nil {
+		log.Println(err)
+	}
+	return &kafkaPublisher{producer: producer}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, event entity.OutboxEntity) error {
+	if p.producer == nil {
+		return errors.New("kafka producer is not connected")
+	}
+	msg := &sarama.ProducerMessage{
+		Topic: event.Topic,
+		Value: sarama.ByteEncoder(event.Payload),
+	}
+	if event.Key != "" {
+		msg.Key = sarama.StringEncoder(event.Key)
+	}
+	headers, err := headers(event)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	if len(headers) > 0 {
+		log.Printf("Sending to Kafka : %s %s\n", headers["ce_type"], headers["ce_id"])
+	} else {
+		log.Println("Sending to Kafka : ", string(event.Payload))
+	}
+	_, _, err = p.producer.SendMessage(msg)
+	return err
+}
+
+func (p *kafkaPublisher) Close() error {
+	if p.producer == nil {
+		return nil
+	}
+	return p.producer.Close()
+}
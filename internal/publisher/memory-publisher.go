@@ -0,0 +1,44 @@
+package publisher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+)
+
+// MemoryPublisher hands every event to the subscribers of its topic, in
+// the publishing goroutine. Nothing is kept, so an event published while
+// its topic has no subscriber is dropped. It lets the service run without
+// a broker in tests and small deployments.
+type MemoryPublisher struct {
+	mu          sync.RWMutex
+	subscribers map[string][]func(entity.OutboxEntity)
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{
+		subscribers: map[string][]func(entity.OutboxEntity){},
+	}
+}
+
+// Subscribe calls handle with every event later published on topic.
+func (p *MemoryPublisher) Subscribe(topic string, handle func(entity.OutboxEntity)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscribers[topic] = append(p.subscribers[topic], handle)
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event entity.OutboxEntity) error {
+	p.mu.RLock()
+	subscribers := p.subscribers[event.Topic]
+	p.mu.RUnlock()
+	for _, handle := range subscribers {
+		handle(event)
+	}
+	return nil
+}
+
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package publisher
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"github.com/nats-io/nats.go"
+)
+
+type natsPublisher struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	stream string
+	prefix string
+
+	mu      sync.Mutex
+	ensured bool
+}
+
+// NewNatsPublisher publishes to JetStream at NATS_URL (default
+// nats://nats:4222), on the subject NATS_SUBJECT_PREFIX (default
+// koalachat.user.) followed by the topic. The stream NATS_STREAM (default
+// KOALACHAT_USER) over those subjects is created when missing. JetStream
+// keeps the order of a subject, and drops a message published twice by its
+// event id.
+func NewNatsPublisher() port.EventPublisher {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		url = "nats://nats:4222"
+	}
+	stream := os.Getenv("NATS_STREAM")
+	if stream == "" {
+		stream = "KOALACHAT_USER"
+	}
+	prefix := os.Getenv("NATS_SUBJECT_PREFIX")
+	if prefix == "" {
+		prefix = "koalachat.user."
+	}
+	p := &natsPublisher{
+		stream: stream,
+		prefix: prefix,
+	}
+	conn, err := nats.Connect(url, nats.Name("koalachat-user"), nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+	if err != nil {
+		log.Println(err)
+		return p
+	}
+	p.conn = conn
+	p.js, err = conn.JetStream()
+	if err != nil {
+		log.Println(err)
+	}
+	return p
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, event entity.OutboxEntity) error {
+	if p.js == nil {
+		return nats.ErrConnectionClosed
+	}
+	if err := p.ensureStream(); err != nil {
+		return err
+	}
+	headers, err := headers(event)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(p.prefix + event.Topic)
+	msg.Data = event.Payload
+	var opts []nats.PubOpt
+	for key, value := range headers {
+		// the NATS binding of CloudEvents prefixes attributes with ce-
+		msg.Header.Set(strings.Replace(key, "ce_", "ce-", 1), value)
+	}
+	if id := headers["ce_id"]; id != "" {
+		opts = append(opts, nats.MsgId(id))
+	}
+	if ctx != nil {
+		opts = append(opts, nats.Context(ctx))
+	}
+	log.Printf("Sending to NATS : %s %s\n", msg.Subject, headers["ce_id"])
+	_, err = p.js.PublishMsg(msg, opts...)
+	return err
+}
+
+// ensureStream creates the stream once, on the first publish, as the
+// server may not be reachable at startup.
+func (p *natsPublisher) ensureStream() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ensured {
+		return nil
+	}
+	if _, err := p.js.StreamInfo(p.stream); err != nil {
+		// this client reports a missing stream only by its description
+		_, err = p.js.AddStream(&nats.StreamConfig{
+			Name:     p.stream,
+			Subjects: []string{p.prefix + ">"},
+			Storage:  nats.FileStorage,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	p.ensured = true
+	return nil
+}
+
+func (p *natsPublisher) Close() error {
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.Drain()
+}
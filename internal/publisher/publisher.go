@@ -0,0 +1,47 @@
+package publisher
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bytedance/sonic"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// NewEventPublisher returns the publisher selected by EVENT_BUS: "nats"
+// publishes to NATS JetStream, "memory" keeps events in the process, and
+// anything else publishes to Kafka.
+func NewEventPublisher() port.EventPublisher {
+	switch os.Getenv("EVENT_BUS") {
+	case "nats":
+		return NewNatsPublisher()
+	case "memory":
+		return NewMemoryPublisher()
+	case "", "kafka":
+	default:
+		log.SetPrefix("[Warning] ")
+		log.Println("unknown EVENT_BUS " + os.Getenv("EVENT_BUS") + ", falling back to kafka")
+	}
+	return NewKafkaPublisher()
+}
+
+// headers returns the headers stored with event, adding its key and
+// sequence as the partitionkey and sequence CloudEvents extensions.
+func headers(event entity.OutboxEntity) (map[string]string, error) {
+	headers := map[string]string{}
+	if len(event.Headers) > 0 {
+		if err := sonic.Unmarshal(event.Headers, &headers); err != nil {
+			return nil, err
+		}
+	}
+	if event.Key != "" {
+		headers["ce_partitionkey"] = event.Key
+	}
+	if event.Sequence > 0 {
+		// the sequence extension compares as a string, hence the padding
+		headers["ce_sequence"] = fmt.Sprintf("%020d", event.Sequence)
+	}
+	return headers, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type availabilityRepository struct {
+	redis *redis.Client
+}
+
+// NewAvailabilityRepository caches whether a username or email is taken in
+// redis under available:<field>:<value>, holding 1 when taken and 0 when
+// free.
+func NewAvailabilityRepository() port.AvailabilityCache {
+	return &availabilityRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func availableKey(field string, value string) string { return "available:" + field + ":" + value }
+
+func (a *availabilityRepository) Get(field string, value string) (bool, bool, error) {
+	taken, err := a.redis.Get(context.Background(), availableKey(field, value)).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return taken == "1", true, nil
+}
+
+func (a *availabilityRepository) Set(field string, value string, taken bool, ttl time.Duration) error {
+	flag := "0"
+	if taken {
+		flag = "1"
+	}
+	return a.redis.Set(context.Background(), availableKey(field, value), flag, ttl).Err()
+}
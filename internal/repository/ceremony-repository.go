@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type ceremonyRepository struct {
+	redis *redis.Client
+}
+
+// NewCeremonyRepository keeps WebAuthn ceremonies in redis under
+// webauthn:<id>.
+func NewCeremonyRepository() port.CeremonyStore {
+	return &ceremonyRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func ceremonyKey(id string) string { return "webauthn:" + id }
+
+func (c *ceremonyRepository) Save(id string, state []byte, ttl time.Duration) error {
+	return c.redis.Set(context.Background(), ceremonyKey(id), state, ttl).Err()
+}
+
+func (c *ceremonyRepository) Take(id string) ([]byte, bool, error) {
+	b, err := c.redis.GetDel(context.Background(), ceremonyKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
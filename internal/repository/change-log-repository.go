@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+const changeStream = "user:changes"
+
+type changeLogRepository struct {
+	redis *redis.Client
+}
+
+// NewChangeLogRepository keeps the change feed in the redis stream
+// user:changes, whose entry ids are the change ids.
+func NewChangeLogRepository() port.ChangeLog {
+	return &changeLogRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func (c *changeLogRepository) Append(change entity.ChangeEntity, max int64) (string, error) {
+	return c.redis.XAdd(context.Background(), &redis.XAddArgs{
+		Stream: changeStream,
+		MaxLen: max,
+		Approx: true,
+		Values: map[string]interface{}{
+			"subject": change.Subject,
+			"type":    change.Type,
+			"event":   []byte(change.Event),
+		},
+	}).Result()
+}
+
+func (c *changeLogRepository) Last() (string, error) {
+	messages, err := c.redis.XRevRangeN(context.Background(), changeStream, "+", "-", 1).Result()
+	if err != nil || len(messages) == 0 {
+		return "", err
+	}
+	return messages[0].ID, nil
+}
+
+func (c *changeLogRepository) Since(id string, limit int64) ([]entity.ChangeEntity, bool, error) {
+	ctx := context.Background()
+	found, err := c.redis.XRangeN(ctx, changeStream, id, id, 1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	messages, err := c.redis.XRangeN(ctx, changeStream, "("+id, "+", limit).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	return changes(messages), len(found) == 1, nil
+}
+
+func (c *changeLogRepository) Tail(ctx context.Context, id string, block time.Duration) ([]entity.ChangeEntity, error) {
+	if id == "" {
+		id = "0-0"
+	}
+	streams, err := c.redis.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{changeStream, id},
+		Count:   100,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) || len(streams) == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return changes(streams[0].Messages), nil
+}
+
+func changes(messages []redis.XMessage) []entity.ChangeEntity {
+	changes := make([]entity.ChangeEntity, 0, len(messages))
+	for _, message := range messages {
+		subject, _ := message.Values["subject"].(string)
+		typ, _ := message.Values["type"].(string)
+		event, _ := message.Values["event"].(string)
+		changes = append(changes, entity.ChangeEntity{
+			ID:      message.ID,
+			Subject: subject,
+			Type:    typ,
+			Event:   []byte(event),
+		})
+	}
+	return changes
+}
@@ -0,0 +1,21 @@
+package repository
+
+// Close releases the process wide DB pool and redis client. Every redis
+// user, the user cache included, shares the client of NewRedisClient, so
+// nothing is left open. Repositories must not be used afterwards.
+func Close() error {
+	var first error
+	if repo.db != nil {
+		db, err := repo.db.DB()
+		if err == nil {
+			err = db.Close()
+		}
+		first = err
+	}
+	if redisClient.client != nil {
+		if err := redisClient.client.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type jobRepository struct {
+	db *gorm.DB
+}
+
+// NewJobRepository keeps retries in retry_jobs and exhausted jobs in
+// dead_letter_jobs.
+func NewJobRepository() port.JobRepository {
+	NewUserRepository()
+	return &jobRepository{
+		db: repo.db,
+	}
+}
+
+func (j *jobRepository) Schedule(job entity.JobEntity) error {
+	return j.db.Save(&job).Error
+}
+
+func (j *jobRepository) Claim(limit int, lease time.Duration) ([]entity.JobEntity, error) {
+	var jobs []entity.JobEntity
+	err := j.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("next_run_at <= ?", now).Order("next_run_at").Limit(limit).Find(&jobs).Error; err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(jobs))
+		for i := range jobs {
+			jobs[i].Stored = true
+			ids = append(ids, jobs[i].ID)
+		}
+		return tx.Model(&entity.JobEntity{}).Where("id IN ?", ids).Update("next_run_at", now.Add(lease)).Error
+	})
+	return jobs, err
+}
+
+func (j *jobRepository) Complete(id string) error {
+	return j.db.Where("id=?", id).Delete(&entity.JobEntity{}).Error
+}
+
+func (j *jobRepository) Bury(job entity.DeadJobEntity) error {
+	return j.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id=?", job.ID).Delete(&entity.JobEntity{}).Error; err != nil {
+			return err
+		}
+		return tx.Save(&job).Error
+	})
+}
+
+func (j *jobRepository) DeadLetters(limit int) ([]entity.DeadJobEntity, error) {
+	var jobs []entity.DeadJobEntity
+	err := j.db.Order("dead_at DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+func (j *jobRepository) Unbury(id string) (entity.JobEntity, bool, error) {
+	var job entity.DeadJobEntity
+	err := j.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", id).First(&job).Error; err != nil {
+			return err
+		}
+		return tx.Where("id=?", id).Delete(&entity.DeadJobEntity{}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.JobEntity{}, false, nil
+	}
+	if err != nil {
+		return entity.JobEntity{}, false, err
+	}
+	return job.JobEntity, true, nil
+}
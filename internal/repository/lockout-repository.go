@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type lockoutRepository struct {
+	redis *redis.Client
+}
+
+// failScript increments a failure counter, starting its window on the first
+// failure. KEYS: counter. ARGV: window ms.
+var failScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return n
+`)
+
+// NewLockoutRepository counts failed logins in redis under
+// login-fail:<key> and keeps locks under login-lock:<key>.
+func NewLockoutRepository() port.LockoutStore {
+	return &lockoutRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func loginFailKey(key string) string { return "login-fail:" + key }
+func loginLockKey(key string) string { return "login-lock:" + key }
+
+func (l *lockoutRepository) Locked(keys ...string) (time.Duration, error) {
+	ctx := context.Background()
+	cmds := make([]*redis.DurationCmd, 0, len(keys))
+	_, err := l.redis.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, key := range keys {
+			cmds = append(cmds, p.PTTL(ctx, loginLockKey(key)))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var longest time.Duration
+	for _, cmd := range cmds {
+		if d := cmd.Val(); d > longest {
+			longest = d
+		}
+	}
+	return longest, nil
+}
+
+func (l *lockoutRepository) Fail(key string, window time.Duration) (int64, error) {
+	return failScript.Run(context.Background(), l.redis, []string{loginFailKey(key)}, window.Milliseconds()).Int64()
+}
+
+func (l *lockoutRepository) Lock(key string, d time.Duration) error {
+	return l.redis.Set(context.Background(), loginLockKey(key), 1, d).Err()
+}
+
+func (l *lockoutRepository) Clear(key string) (bool, error) {
+	ctx := context.Background()
+	var locked *redis.IntCmd
+	_, err := l.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		locked = p.Del(ctx, loginLockKey(key))
+		p.Del(ctx, loginFailKey(key))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return locked.Val() == 1, nil
+}
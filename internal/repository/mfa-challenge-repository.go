@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type mfaChallengeRepository struct {
+	redis *redis.Client
+}
+
+// attemptScript counts a try against a challenge and drops the challenge
+// once it exceeded its tries. KEYS: challenge. ARGV: max attempts.
+var attemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then return false end
+local n = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if n > tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[1])
+	return false
+end
+return redis.call('HGET', KEYS[1], 'uuid')
+`)
+
+// createScript starts a challenge unless its account started too many in
+// the current window. KEYS: challenge, account counter. ARGV: uuid, ttl in
+// milliseconds, max challenges.
+var createScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[2])
+if n == 1 then redis.call('PEXPIRE', KEYS[2], ARGV[2]) end
+if n > tonumber(ARGV[3]) then return 0 end
+redis.call('HSET', KEYS[1], 'uuid', ARGV[1], 'attempts', 0)
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// NewMfaChallengeRepository stores pending second login steps in redis
+// under mfa-login:<hash> and counts them per account under
+// mfa-logins:<uuid>.
+func NewMfaChallengeRepository() port.MfaChallengeStore {
+	return &mfaChallengeRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func mfaLoginKey(hash string) string      { return "mfa-login:" + hash }
+func mfaLoginCountKey(uuid string) string { return "mfa-logins:" + uuid }
+
+func (m *mfaChallengeRepository) Create(tokenHash string, uuid string, ttl time.Duration, maxChallenges int) (bool, error) {
+	keys := []string{mfaLoginKey(tokenHash), mfaLoginCountKey(uuid)}
+	created, err := createScript.Run(context.Background(), m.redis, keys, uuid, ttl.Milliseconds(), maxChallenges).Int()
+	if err != nil {
+		return false, err
+	}
+	return created == 1, nil
+}
+
+func (m *mfaChallengeRepository) Attempt(tokenHash string, maxAttempts int) (string, bool, error) {
+	uuid, err := attemptScript.Run(context.Background(), m.redis, []string{mfaLoginKey(tokenHash)}, maxAttempts).Text()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return uuid, true, nil
+}
+
+func (m *mfaChallengeRepository) Delete(tokenHash string) error {
+	return m.redis.Del(context.Background(), mfaLoginKey(tokenHash)).Err()
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+// NewMfaRepository stores MFA factors next to the users, so
+// NewUserRepository must have run first.
+func NewMfaRepository() port.MfaRepository {
+	NewUserRepository()
+	return &mfaRepository{
+		db: repo.db,
+	}
+}
+
+func (m *mfaRepository) Get(uuid string) (entity.MfaEntity, bool, error) {
+	var mfa []entity.MfaEntity
+	if err := m.db.Where("uuid=?", uuid).Limit(1).Find(&mfa).Error; err != nil {
+		return entity.MfaEntity{}, false, err
+	}
+	if len(mfa) == 0 {
+		return entity.MfaEntity{}, false, nil
+	}
+	return mfa[0], true, nil
+}
+
+func (m *mfaRepository) SavePending(mfa entity.MfaEntity) (bool, error) {
+	mfa.Enabled = false
+	res := m.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uuid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "last_step", "created_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: "user_mfa", Name: "enabled"}, Value: false}}},
+	}).Create(&mfa)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (m *mfaRepository) Enable(uuid string, codeHashes []string) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		var mfa entity.MfaEntity
+		if err := tx.Model(&mfa).Where("uuid=?", uuid).Update("enabled", true).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("uuid=?", uuid).Delete(&entity.RecoveryCodeEntity{}).Error; err != nil {
+			return err
+		}
+		codes := make([]entity.RecoveryCodeEntity, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, entity.RecoveryCodeEntity{Hash: hash, Uuid: uuid})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (m *mfaRepository) Delete(uuid string) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uuid=?", uuid).Delete(&entity.RecoveryCodeEntity{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("uuid=?", uuid).Delete(&entity.MfaEntity{}).Error
+	})
+}
+
+func (m *mfaRepository) UseStep(uuid string, step int64) (bool, error) {
+	var mfa entity.MfaEntity
+	res := m.db.Model(&mfa).Where("uuid=? AND last_step<?", uuid, step).Update("last_step", step)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (m *mfaRepository) UseRecoveryCode(uuid string, hash string) (bool, error) {
+	res := m.db.Where("uuid=? AND hash=?", uuid, hash).Delete(&entity.RecoveryCodeEntity{})
+	return res.RowsAffected == 1, res.Error
+}
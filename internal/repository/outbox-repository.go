@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"gorm.io/gorm"
+)
+
+// outboxRelayLock is the postgres advisory lock key held while relaying, so
+// only one process publishes at a time and ordering survives several
+// instances.
+const outboxRelayLock = 0x6b6f616c61
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository() port.OutboxRepository {
+	NewUserRepository()
+	return &outboxRepository{
+		db: repo.db,
+	}
+}
+
+func (o *outboxRepository) Append(event entity.OutboxEntity) error {
+	return o.db.Create(&event).Error
+}
+
+func (o *outboxRepository) Relay(limit int, publish func(entity.OutboxEntity) error) (int, error) {
+	sent := 0
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxRelayLock).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		var events []entity.OutboxEntity
+		if err := tx.Where("sent_at IS NULL").Order("id").Limit(limit).Find(&events).Error; err != nil {
+			return err
+		}
+		var ids []uint64
+		var publishErr error
+		for _, event := range events {
+			if publishErr = publish(event); publishErr != nil {
+				break
+			}
+			ids = append(ids, event.ID)
+		}
+		if len(ids) > 0 {
+			if err := tx.Model(&entity.OutboxEntity{}).Where("id IN ?", ids).Update("sent_at", time.Now()).Error; err != nil {
+				return err
+			}
+		}
+		sent = len(ids)
+		if publishErr != nil && sent == 0 {
+			return publishErr
+		}
+		return nil
+	})
+	return sent, err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"gorm.io/gorm"
+)
+
+type passkeyRepository struct {
+	db *gorm.DB
+}
+
+// NewPasskeyRepository stores passkeys next to the users, so
+// NewUserRepository must have run first.
+func NewPasskeyRepository() port.PasskeyRepository {
+	NewUserRepository()
+	return &passkeyRepository{
+		db: repo.db,
+	}
+}
+
+func (p *passkeyRepository) Save(passkey entity.PasskeyEntity) error {
+	return p.db.Create(&passkey).Error
+}
+
+func (p *passkeyRepository) Get(id string) (entity.PasskeyEntity, bool, error) {
+	var passkeys []entity.PasskeyEntity
+	if err := p.db.Where("id=?", id).Limit(1).Find(&passkeys).Error; err != nil {
+		return entity.PasskeyEntity{}, false, err
+	}
+	if len(passkeys) == 0 {
+		return entity.PasskeyEntity{}, false, nil
+	}
+	return passkeys[0], true, nil
+}
+
+func (p *passkeyRepository) List(uuid string) ([]entity.PasskeyEntity, error) {
+	passkeys := []entity.PasskeyEntity{}
+	err := p.db.Where("uuid=?", uuid).Order("created_at ASC").Find(&passkeys).Error
+	return passkeys, err
+}
+
+func (p *passkeyRepository) Used(id string, signCount uint32, backupState bool, at time.Time) error {
+	var passkey entity.PasskeyEntity
+	return p.db.Model(&passkey).Where("id=?", id).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": at,
+	}).Error
+}
+
+func (p *passkeyRepository) Delete(uuid string, id string) (bool, error) {
+	res := p.db.Where("uuid=? AND id=?", uuid, id).Delete(&entity.PasskeyEntity{})
+	return res.RowsAffected == 1, res.Error
+}
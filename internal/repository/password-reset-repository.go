@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type passwordResetRepository struct {
+	redis *redis.Client
+}
+
+// NewPasswordResetRepository stores pending password resets in redis under
+// reset:<hash>, points reset-user:<uuid> at the latest token of an account
+// and throttles reset mails with reset-mail:<uuid>.
+func NewPasswordResetRepository() port.PasswordResetStore {
+	return &passwordResetRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func resetKey(hash string) string     { return "reset:" + hash }
+func resetUserKey(uuid string) string { return "reset-user:" + uuid }
+func resetMailKey(uuid string) string { return "reset-mail:" + uuid }
+
+func (r *passwordResetRepository) Create(tokenHash string, uuid string, ttl time.Duration) error {
+	ctx := context.Background()
+	old, err := r.redis.GetSet(ctx, resetUserKey(uuid), tokenHash).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	_, err = r.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if old != "" {
+			p.Del(ctx, resetKey(old))
+		}
+		p.Set(ctx, resetKey(tokenHash), uuid, ttl)
+		p.Expire(ctx, resetUserKey(uuid), ttl)
+		return nil
+	})
+	return err
+}
+
+func (r *passwordResetRepository) Peek(tokenHash string) (string, bool, error) {
+	uuid, err := r.redis.Get(context.Background(), resetKey(tokenHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return uuid, true, nil
+}
+
+func (r *passwordResetRepository) Consume(tokenHash string) (string, bool, error) {
+	uuid, err := r.redis.GetDel(context.Background(), resetKey(tokenHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return uuid, true, nil
+}
+
+func (r *passwordResetRepository) Throttle(uuid string, interval time.Duration) (bool, error) {
+	return r.redis.SetNX(context.Background(), resetMailKey(uuid), 1, interval).Result()
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"os"
+	"sync"
+
+	"github.com/go-redis/redis/v9"
+)
+
+var redisClient struct {
+	once   sync.Once
+	client *redis.Client
+}
+
+// NewRedisClient returns the process wide redis client, configured from
+// REDIS_ADDR and REDIS_PASSWORD.
+func NewRedisClient() *redis.Client {
+	redisClient.once.Do(func() {
+		addr := os.Getenv("REDIS_ADDR")
+		if addr == "" {
+			addr = "localhost:6379"
+		}
+		redisClient.client = redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: os.Getenv("REDIS_PASSWORD"),
+			DB:       0,
+		})
+	})
+	return redisClient.client
+}
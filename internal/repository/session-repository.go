@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+// sessionRecord is the stored form of a session; unlike the entity it keeps
+// the current refresh token hash.
+type sessionRecord struct {
+	entity.SessionEntity
+	TokenHash string `json:"token_hash"`
+}
+
+type sessionRepository struct {
+	redis *redis.Client
+}
+
+// rotateScript swaps the current refresh token hash of a session only if the
+// caller presented the current one, so two concurrent refreshes with the same
+// token cannot both succeed.
+// KEYS: session, new refresh, old refresh. ARGV: old hash, record, session id, ttl ms.
+var rotateScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if not cur then return 0 end
+if cjson.decode(cur).token_hash ~= ARGV[1] then return 0 end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[4])
+redis.call('SET', KEYS[2], ARGV[3], 'PX', ARGV[4])
+redis.call('PEXPIRE', KEYS[3], ARGV[4])
+return 1
+`)
+
+// NewSessionRepository stores sessions in redis under session:<id>, maps
+// refresh token hashes to sessions under refresh:<hash> and indexes the
+// sessions of a user in the set sessions:<uuid>.
+func NewSessionRepository() port.SessionStore {
+	return &sessionRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func sessionKey(id string) string    { return "session:" + id }
+func refreshKey(hash string) string  { return "refresh:" + hash }
+func sessionsKey(uuid string) string { return "sessions:" + uuid }
+
+func (s *sessionRepository) Create(session entity.SessionEntity) error {
+	b, err := sonic.Marshal(&sessionRecord{SessionEntity: session, TokenHash: session.TokenHash})
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(session.ExpiresAt)
+	ctx := context.Background()
+	_, err = s.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, sessionKey(session.ID), b, ttl)
+		p.Set(ctx, refreshKey(session.TokenHash), session.ID, ttl)
+		p.SAdd(ctx, sessionsKey(session.Uuid), session.ID)
+		return nil
+	})
+	return err
+}
+
+func (s *sessionRepository) Lookup(tokenHash string) (entity.SessionEntity, bool, error) {
+	ctx := context.Background()
+	id, err := s.redis.Get(ctx, refreshKey(tokenHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return entity.SessionEntity{}, false, nil
+	}
+	if err != nil {
+		return entity.SessionEntity{}, false, err
+	}
+	return s.get(ctx, id)
+}
+
+func (s *sessionRepository) get(ctx context.Context, id string) (entity.SessionEntity, bool, error) {
+	b, err := s.redis.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return entity.SessionEntity{}, false, nil
+	}
+	if err != nil {
+		return entity.SessionEntity{}, false, err
+	}
+	var record sessionRecord
+	if err := sonic.Unmarshal(b, &record); err != nil {
+		return entity.SessionEntity{}, false, err
+	}
+	record.SessionEntity.TokenHash = record.TokenHash
+	return record.SessionEntity, true, nil
+}
+
+func (s *sessionRepository) Rotate(session entity.SessionEntity, oldHash string) (bool, error) {
+	b, err := sonic.Marshal(&sessionRecord{SessionEntity: session, TokenHash: session.TokenHash})
+	if err != nil {
+		return false, err
+	}
+	ttl := time.Until(session.ExpiresAt)
+	res, err := rotateScript.Run(context.Background(), s.redis,
+		[]string{sessionKey(session.ID), refreshKey(session.TokenHash), refreshKey(oldHash)},
+		oldHash, b, session.ID, ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (s *sessionRepository) List(uuid string) ([]entity.SessionEntity, error) {
+	ctx := context.Background()
+	ids, err := s.redis.SMembers(ctx, sessionsKey(uuid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := []entity.SessionEntity{}
+	for _, id := range ids {
+		session, found, err := s.get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// expired on its own, drop it from the index
+			s.redis.SRem(ctx, sessionsKey(uuid), id)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *sessionRepository) Revoke(sessionID string) error {
+	ctx := context.Background()
+	session, found, err := s.get(ctx, sessionID)
+	if err != nil || !found {
+		return err
+	}
+	_, err = s.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, sessionKey(sessionID))
+		p.SRem(ctx, sessionsKey(session.Uuid), sessionID)
+		return nil
+	})
+	return err
+}
+
+func (s *sessionRepository) RevokeAll(uuid string) error {
+	ctx := context.Background()
+	ids, err := s.redis.SMembers(ctx, sessionsKey(uuid)).Result()
+	if err != nil {
+		return err
+	}
+	_, err = s.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for _, id := range ids {
+			p.Del(ctx, sessionKey(id))
+		}
+		p.Del(ctx, sessionsKey(uuid))
+		return nil
+	})
+	return err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"log"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/koalachatapp/user/internal/core/entity"
@@ -47,7 +48,15 @@ func NewUserRepository() port.UserRepository {
 			// Logger:  logger.Default.LogMode(logger.Error),
 			SkipDefaultTransaction: true,
 		})
-		db.AutoMigrate(&entity.UserEntity{})
+		// accounts from before email verification keep logging in; only
+		// the ones registered from now on have to verify
+		grandfather := err == nil && db.Migrator().HasTable(&entity.UserEntity{}) && !db.Migrator().HasColumn(&entity.UserEntity{}, "Verified")
+		db.AutoMigrate(&entity.UserEntity{}, &entity.OutboxEntity{}, &entity.UserEventSequenceEntity{}, &entity.JobEntity{}, &entity.DeadJobEntity{}, &entity.MfaEntity{}, &entity.RecoveryCodeEntity{}, &entity.PasskeyEntity{}, &entity.WebhookEntity{}, &entity.WebhookDeliveryEntity{}, &entity.WebhookAttemptEntity{})
+		if grandfather {
+			if err := db.Model(&entity.UserEntity{}).Where("verified=?", false).Update("verified", true).Error; err != nil {
+				log.Println(err)
+			}
+		}
 		if err != nil {
 			log.SetPrefix("[Warning] ")
 			log.Println(err)
@@ -57,49 +66,139 @@ func NewUserRepository() port.UserRepository {
 	return repo
 }
 
-func (u *userRepository) Save(user entity.UserEntity) error {
-	res := u.db.Create(&user)
-	return res.Error
+func (u *userRepository) Save(user entity.UserEntity, event entity.OutboxEntity) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return appendEvent(tx, event)
+	})
+}
+
+func (u *userRepository) Update(uuid string, user entity.UserEntity, event entity.OutboxEntity) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		var users entity.UserEntity
+		if err := unverify(tx, uuid, user.Email); err != nil {
+			return err
+		}
+		if err := tx.Model(&users).Where("uuid=?", uuid).Updates(user).Error; err != nil {
+			return err
+		}
+		return appendEvent(tx, event)
+	})
+}
+
+// Patch updates only the non-empty fields of user.
+func (u *userRepository) Patch(uuid string, user entity.UserEntity, event entity.OutboxEntity) error {
+	columns := map[string]interface{}{}
+	for _, param := range [][2]string{
+		{"email", user.Email},
+		{"name", user.Name},
+		{"password", user.Password},
+		{"username", user.Username},
+	} {
+		if param[1] != "" {
+			columns[param[0]] = param[1]
+		}
+	}
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		var users entity.UserEntity
+		if err := unverify(tx, uuid, user.Email); err != nil {
+			return err
+		}
+		if err := tx.Model(&users).Where("uuid=?", uuid).Updates(columns).Error; err != nil {
+			return err
+		}
+		return appendEvent(tx, event)
+	})
+}
+
+// appendEvent writes event to the outbox, numbering it after the previous
+// event keyed by the same user. The counter row stays locked until tx ends,
+// so the outbox ids of a user's events follow their sequence.
+func appendEvent(tx *gorm.DB, event entity.OutboxEntity) error {
+	if event.Key != "" {
+		err := tx.Raw(`INSERT INTO user_event_sequences (uuid, sequence) VALUES (?, 1)
+			ON CONFLICT (uuid) DO UPDATE SET sequence = user_event_sequences.sequence + 1
+			RETURNING sequence`, event.Key).Scan(&event.Sequence).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Create(&event).Error
 }
 
-func (u *userRepository) Update(uuid string, user entity.UserEntity) error {
+// unverify clears the verified state of uuid when email is about to replace
+// its address.
+func unverify(tx *gorm.DB, uuid string, email string) error {
+	if email == "" {
+		return nil
+	}
 	var users entity.UserEntity
-	tx := u.db.Model(&users).Where("uuid=?", uuid).Updates(user)
-	return tx.Error
+	return tx.Model(&users).Where("uuid=? AND email<>?", uuid, email).Update("verified", false).Error
+}
+
+func (u *userRepository) MarkVerified(uuid string, email string, event entity.OutboxEntity) (bool, error) {
+	verified := false
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		var users entity.UserEntity
+		res := tx.Model(&users).Where("uuid=? AND email=?", uuid, email).Update("verified", true)
+		if res.Error != nil || res.RowsAffected != 1 {
+			return res.Error
+		}
+		verified = true
+		return appendEvent(tx, event)
+	})
+	if err != nil {
+		return false, err
+	}
+	return verified, nil
 }
 
-func (u *userRepository) Patch(uuid string, user entity.UserEntity) error {
+func (u *userRepository) UpdatePassword(uuid string, hash string) error {
 	var users entity.UserEntity
-	if user.Username != "" {
-		u.db.Model(users).Where("uuid=?", uuid).UpdateColumn("username", user.Username)
-	}
-	if err := func(param ...[2]string) error {
-		for _, param := range param {
-			if param[0] != "" && param[1] != "" {
-				if tx := u.db.Model(users).Where("uuid=? and "+param[0]+"=?", uuid, param[1]).Update(param[0], param[1]); tx.Error != nil {
-					return tx.Error
-				}
-			}
+	tx := u.db.Model(&users).Where("uuid=?", uuid).Update("password", hash)
+	return tx.Error
+}
+
+func (u *userRepository) ResetPassword(uuid string, hash string, event entity.OutboxEntity) (bool, error) {
+	reset := false
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		var users entity.UserEntity
+		res := tx.Model(&users).Where("uuid=?", uuid).Update("password", hash)
+		if res.Error != nil || res.RowsAffected != 1 {
+			return res.Error
 		}
-		return nil
-	}(
-		[2]string{"email", user.Email},
-		[2]string{"name", user.Name},
-		[2]string{"password", user.Password},
-		[2]string{"username", user.Username},
-	); err != nil {
-		return err
+		reset = true
+		return appendEvent(tx, event)
+	})
+	if err != nil {
+		return false, err
 	}
-	return nil
+	return reset, nil
 }
 
-func (u *userRepository) Delete(uuid string) (bool, error) {
-	var user entity.UserEntity
-	tx := u.db.Where("uuid=?", uuid).Delete(&user)
-	if tx.RowsAffected == 1 {
-		return true, nil
+func (u *userRepository) Delete(uuid string, event entity.OutboxEntity) (bool, error) {
+	deleted := false
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		var user entity.UserEntity
+		res := tx.Where("uuid=?", uuid).Delete(&user)
+		if res.Error != nil || res.RowsAffected != 1 {
+			return res.Error
+		}
+		deleted = true
+		// the factors of the user go with it
+		for _, factor := range []interface{}{&entity.MfaEntity{}, &entity.RecoveryCodeEntity{}, &entity.PasskeyEntity{}} {
+			if err := tx.Where("uuid=?", uuid).Delete(factor).Error; err != nil {
+				return err
+			}
+		}
+		return appendEvent(tx, event)
+	})
+	if err != nil {
+		return false, err
 	}
-	return false, tx.Error
+	return deleted, nil
 }
 
 func (u *userRepository) IsExistUuid(uuid string) (bool, error) {
@@ -112,14 +211,90 @@ func (u *userRepository) IsExistUuid(uuid string) (bool, error) {
 }
 
 func (u *userRepository) IsExist(username string, email string) (bool, error) {
-
+	// username and email may belong to two different users
 	var users []entity.UserEntity
-	tx := u.db.Where("username=? OR email=?", username, email).Find(&users)
+	tx := u.db.Select("uuid").Where("username=? OR email=?", username, email).Limit(1).Find(&users)
 	if tx.Error != nil {
 		return false, tx.Error
 	}
+	return len(users) > 0, nil
+}
+
+// TakenUsernames returns which of usernames belong to a user.
+func (u *userRepository) TakenUsernames(usernames []string) ([]string, error) {
+	var taken []string
+	tx := u.db.Model(&entity.UserEntity{}).Where("username IN ?", usernames).Pluck("username", &taken)
+	return taken, tx.Error
+}
+
+// FindByLogin looks a user up by username or email.
+func (u *userRepository) FindByLogin(login string) (entity.UserEntity, bool, error) {
+	return u.first("username=? OR email=?", login, login)
+}
+
+func (u *userRepository) Get(uuid string) (entity.UserEntity, bool, error) {
+	return u.first("uuid=?", uuid)
+}
+
+func (u *userRepository) GetByUsername(username string) (entity.UserEntity, bool, error) {
+	return u.first("username=?", username)
+}
+
+func (u *userRepository) GetByEmail(email string) (entity.UserEntity, bool, error) {
+	return u.first("email=?", email)
+}
+
+func (u *userRepository) List(query entity.ListQuery) ([]entity.UserEntity, bool, error) {
+	tx := u.db.Model(&entity.UserEntity{})
+	if query.Prefix != "" {
+		prefix := likeEscaper.Replace(query.Prefix) + "%"
+		tx = tx.Where("username LIKE ? OR email LIKE ?", prefix, prefix)
+	}
+	if !query.CreatedAfter.IsZero() {
+		tx = tx.Where("created_at >= ?", query.CreatedAfter)
+	}
+	if !query.CreatedBefore.IsZero() {
+		tx = tx.Where("created_at < ?", query.CreatedBefore)
+	}
+	backward := query.Key != nil && query.Key.Backward
+	if query.Key != nil {
+		op := ">"
+		if backward {
+			op = "<"
+		}
+		tx = tx.Where("(created_at, uuid) "+op+" (?, ?)", query.Key.CreatedAt, query.Key.Uuid)
+	}
+	if backward {
+		tx = tx.Order("created_at DESC, uuid DESC")
+	} else {
+		tx = tx.Order("created_at ASC, uuid ASC")
+	}
+	var users []entity.UserEntity
+	if err := tx.Limit(query.Limit + 1).Find(&users).Error; err != nil {
+		return nil, false, err
+	}
+	more := len(users) > query.Limit
+	if more {
+		users = users[:query.Limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+	return users, more, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (u *userRepository) first(query string, args ...interface{}) (entity.UserEntity, bool, error) {
+	var users []entity.UserEntity
+	tx := u.db.Where(query, args...).Limit(1).Find(&users)
+	if tx.Error != nil {
+		return entity.UserEntity{}, false, tx.Error
+	}
 	if len(users) == 1 {
-		return true, nil
+		return users[0], true, nil
 	}
-	return false, nil
+	return entity.UserEntity{}, false, nil
 }
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/go-redis/redis/v9"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type verificationRepository struct {
+	redis *redis.Client
+}
+
+// NewVerificationRepository stores pending email verifications in redis
+// under verify:<hash>, points verify-user:<uuid> at the latest token of an
+// account and throttles resends with verify-resend:<uuid>.
+func NewVerificationRepository() port.VerificationStore {
+	return &verificationRepository{
+		redis: NewRedisClient(),
+	}
+}
+
+func verifyKey(hash string) string       { return "verify:" + hash }
+func verifyUserKey(uuid string) string   { return "verify-user:" + uuid }
+func verifyResendKey(uuid string) string { return "verify-resend:" + uuid }
+
+func (v *verificationRepository) Create(tokenHash string, verification entity.VerificationEntity, ttl time.Duration) error {
+	b, err := sonic.Marshal(&verification)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	old, err := v.redis.GetSet(ctx, verifyUserKey(verification.Uuid), tokenHash).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	_, err = v.redis.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if old != "" {
+			p.Del(ctx, verifyKey(old))
+		}
+		p.Set(ctx, verifyKey(tokenHash), b, ttl)
+		p.Expire(ctx, verifyUserKey(verification.Uuid), ttl)
+		return nil
+	})
+	return err
+}
+
+func (v *verificationRepository) Consume(tokenHash string) (entity.VerificationEntity, bool, error) {
+	b, err := v.redis.GetDel(context.Background(), verifyKey(tokenHash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return entity.VerificationEntity{}, false, nil
+	}
+	if err != nil {
+		return entity.VerificationEntity{}, false, err
+	}
+	var verification entity.VerificationEntity
+	if err := sonic.Unmarshal(b, &verification); err != nil {
+		return entity.VerificationEntity{}, false, err
+	}
+	return verification, true, nil
+}
+
+func (v *verificationRepository) Throttle(uuid string, interval time.Duration) (bool, error) {
+	return v.redis.SetNX(context.Background(), verifyResendKey(uuid), 1, interval).Result()
+}
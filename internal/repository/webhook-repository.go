@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository keeps webhooks in webhooks, their deliveries in
+// webhook_deliveries and every attempt in webhook_attempts.
+func NewWebhookRepository() port.WebhookRepository {
+	NewUserRepository()
+	return &webhookRepository{
+		db: repo.db,
+	}
+}
+
+func (w *webhookRepository) Save(webhook entity.WebhookEntity) error {
+	return w.db.Save(&webhook).Error
+}
+
+func (w *webhookRepository) Get(id string) (entity.WebhookEntity, bool, error) {
+	var webhooks []entity.WebhookEntity
+	if err := w.db.Where("id=?", id).Limit(1).Find(&webhooks).Error; err != nil {
+		return entity.WebhookEntity{}, false, err
+	}
+	if len(webhooks) == 0 {
+		return entity.WebhookEntity{}, false, nil
+	}
+	return webhooks[0], true, nil
+}
+
+func (w *webhookRepository) List() ([]entity.WebhookEntity, error) {
+	var webhooks []entity.WebhookEntity
+	err := w.db.Order("created_at").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (w *webhookRepository) Delete(id string) (bool, error) {
+	deleted := false
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id=?", id).Delete(&entity.WebhookEntity{})
+		if res.Error != nil || res.RowsAffected != 1 {
+			return res.Error
+		}
+		deleted = true
+		deliveries := tx.Model(&entity.WebhookDeliveryEntity{}).Select("id").Where("webhook_id=?", id)
+		if err := tx.Where("delivery_id IN (?)", deliveries).Delete(&entity.WebhookAttemptEntity{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("webhook_id=?", id).Delete(&entity.WebhookDeliveryEntity{}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+func (w *webhookRepository) Enabled() ([]entity.WebhookEntity, error) {
+	var webhooks []entity.WebhookEntity
+	err := w.db.Where("enabled").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (w *webhookRepository) Enqueue(deliveries []entity.WebhookDeliveryEntity) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return w.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+func (w *webhookRepository) Claim(limit int, lease time.Duration) ([]entity.WebhookDeliveryEntity, error) {
+	var deliveries []entity.WebhookDeliveryEntity
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		enabled := tx.Model(&entity.WebhookEntity{}).Select("id").Where("enabled")
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status=? AND next_attempt_at <= ? AND webhook_id IN (?)", entity.DeliveryPending, now, enabled).
+			Order("next_attempt_at").Limit(limit).Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+		}
+		return tx.Model(&entity.WebhookDeliveryEntity{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
+
+func (w *webhookRepository) Attempted(delivery entity.WebhookDeliveryEntity, attempt entity.WebhookAttemptEntity, disableAfter int) (bool, error) {
+	disabled := false
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&delivery).Error; err != nil {
+			return err
+		}
+		webhook := tx.Model(&entity.WebhookEntity{}).Where("id=?", delivery.WebhookID)
+		switch delivery.Status {
+		case entity.DeliverySucceeded:
+			return webhook.Update("failures", 0).Error
+		case entity.DeliveryFailed:
+			var failures int
+			if err := tx.Raw("UPDATE webhooks SET failures = failures + 1 WHERE id = ? RETURNING failures", delivery.WebhookID).Scan(&failures).Error; err != nil {
+				return err
+			}
+			if failures < disableAfter {
+				return nil
+			}
+			res := tx.Model(&entity.WebhookEntity{}).Where("id=? AND enabled", delivery.WebhookID).
+				Updates(map[string]interface{}{"enabled": false, "disabled_at": time.Now()})
+			disabled = res.RowsAffected == 1
+			return res.Error
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return disabled, nil
+}
+
+func (w *webhookRepository) Deliveries(webhookID string, status string, limit int) ([]entity.WebhookDeliveryEntity, error) {
+	tx := w.db.Where("webhook_id=?", webhookID)
+	if status != "" {
+		tx = tx.Where("status=?", status)
+	}
+	var deliveries []entity.WebhookDeliveryEntity
+	err := tx.Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (w *webhookRepository) Delivery(webhookID string, id string) (entity.WebhookDeliveryEntity, bool, error) {
+	var deliveries []entity.WebhookDeliveryEntity
+	if err := w.db.Where("webhook_id=? AND id=?", webhookID, id).Limit(1).Find(&deliveries).Error; err != nil {
+		return entity.WebhookDeliveryEntity{}, false, err
+	}
+	if len(deliveries) == 0 {
+		return entity.WebhookDeliveryEntity{}, false, nil
+	}
+	delivery := deliveries[0]
+	if err := w.db.Where("delivery_id=?", id).Order("id").Find(&delivery.Log).Error; err != nil {
+		return entity.WebhookDeliveryEntity{}, false, err
+	}
+	return delivery, true, nil
+}
+
+func (w *webhookRepository) Replay(webhookID string, id string) (entity.WebhookDeliveryEntity, bool, error) {
+	var delivery entity.WebhookDeliveryEntity
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("webhook_id=? AND id=?", webhookID, id).First(&delivery).Error; err != nil {
+			return err
+		}
+		delivery.Status = entity.DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now()
+		delivery.Replays++
+		return tx.Save(&delivery).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return entity.WebhookDeliveryEntity{}, false, nil
+	}
+	if err != nil {
+		return entity.WebhookDeliveryEntity{}, false, err
+	}
+	return delivery, true, nil
+}
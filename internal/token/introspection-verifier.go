@@ -0,0 +1,89 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type introspectionVerifier struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+}
+
+type introspectionResponse struct {
+	Active bool   `json:"active"`
+	Sub    string `json:"sub"`
+	Scope  string `json:"scope"`
+	Exp    int64  `json:"exp"`
+}
+
+// NewIntrospectionVerifier asks the auth server at INTROSPECTION_URL whether
+// a token is active (RFC 7662), authenticating with INTROSPECTION_CLIENT_ID
+// and INTROSPECTION_CLIENT_SECRET.
+func NewIntrospectionVerifier() port.TokenVerifier {
+	return &introspectionVerifier{
+		endpoint:     os.Getenv("INTROSPECTION_URL"),
+		clientID:     os.Getenv("INTROSPECTION_CLIENT_ID"),
+		clientSecret: os.Getenv("INTROSPECTION_CLIENT_SECRET"),
+		client:       &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (i *introspectionVerifier) Verify(ctx context.Context, token string) (entity.ClaimsEntity, error) {
+	if i.endpoint == "" {
+		return entity.ClaimsEntity{}, errors.New("no introspection endpoint configured")
+	}
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return entity.ClaimsEntity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	}
+	res, err := i.client.Do(req)
+	if err != nil {
+		return entity.ClaimsEntity{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return entity.ClaimsEntity{}, fmt.Errorf("introspection endpoint returned %s", res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return entity.ClaimsEntity{}, err
+	}
+	var result introspectionResponse
+	if err := sonic.Unmarshal(body, &result); err != nil {
+		return entity.ClaimsEntity{}, err
+	}
+	if !result.Active {
+		return entity.ClaimsEntity{}, errors.New("token is not active")
+	}
+	if result.Sub == "" {
+		return entity.ClaimsEntity{}, errors.New("token has no subject")
+	}
+	claims := entity.ClaimsEntity{
+		Subject: result.Sub,
+		Scopes:  strings.Fields(result.Scope),
+	}
+	if result.Exp != 0 {
+		claims.ExpiresAt = time.Unix(result.Exp, 0)
+	}
+	return claims, nil
+}
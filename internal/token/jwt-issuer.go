@@ -0,0 +1,85 @@
+package token
+
+import (
+	"crypto"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type jwtIssuer struct {
+	method jwt.SigningMethod
+	key    crypto.PrivateKey
+	keyID  string
+	issuer string
+	ttl    time.Duration
+}
+
+// NewJwtIssuer builds a JWT issuer from the environment. JWT_ALG selects
+// EdDSA (default) or RS256, JWT_PRIVATE_KEY_FILE points at the PEM encoded
+// signing key, and JWT_KEY_ID, JWT_ISSUER and JWT_TTL are optional.
+func NewJwtIssuer() port.TokenIssuer {
+	issuer := &jwtIssuer{
+		keyID:  os.Getenv("JWT_KEY_ID"),
+		issuer: os.Getenv("JWT_ISSUER"),
+		ttl:    15 * time.Minute,
+	}
+	if issuer.issuer == "" {
+		issuer.issuer = "koala-user"
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("JWT_TTL")); err == nil {
+		issuer.ttl = ttl
+	}
+	pem, err := os.ReadFile(os.Getenv("JWT_PRIVATE_KEY_FILE"))
+	if err != nil {
+		log.SetPrefix("[Warning] ")
+		log.Println("token signing disabled:", err)
+		return issuer
+	}
+	switch os.Getenv("JWT_ALG") {
+	case "RS256":
+		issuer.method = jwt.SigningMethodRS256
+		issuer.key, err = jwt.ParseRSAPrivateKeyFromPEM(pem)
+	default:
+		issuer.method = jwt.SigningMethodEdDSA
+		issuer.key, err = jwt.ParseEdPrivateKeyFromPEM(pem)
+	}
+	if err != nil {
+		log.SetPrefix("[Warning] ")
+		log.Println("token signing disabled:", err)
+		issuer.key = nil
+	}
+	return issuer
+}
+
+func (j *jwtIssuer) Issue(subject string, sessionID string) (string, time.Time, error) {
+	if j.key == nil {
+		return "", time.Time{}, errors.New("no signing key configured")
+	}
+	now := time.Now()
+	expiresAt := now.Add(j.ttl)
+	t := jwt.NewWithClaims(j.method, accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    j.issuer,
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Sid: sessionID,
+	})
+	if j.keyID != "" {
+		t.Header["kid"] = j.keyID
+	}
+	signed, err := t.SignedString(j.key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
@@ -0,0 +1,121 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+	Sid   string   `json:"sid,omitempty"`
+}
+
+type jwtVerifier struct {
+	static   crypto.PublicKey
+	jwks     *jwksKeySet
+	issuer   string
+	audience string
+	parser   *jwt.Parser
+}
+
+// NewTokenVerifier picks the verifier from TOKEN_VERIFIER: "introspection"
+// asks the auth server about every token, anything else verifies JWTs locally.
+func NewTokenVerifier() port.TokenVerifier {
+	if os.Getenv("TOKEN_VERIFIER") == "introspection" {
+		return NewIntrospectionVerifier()
+	}
+	return NewJwtVerifier()
+}
+
+// NewJwtVerifier verifies JWTs locally. Keys come from the PEM encoded
+// JWT_PUBLIC_KEY_FILE or from JWKS_URL (an http(s) URL or a file path, cached
+// for JWKS_CACHE_TTL). JWT_ISSUER and JWT_AUDIENCE are enforced when set.
+func NewJwtVerifier() port.TokenVerifier {
+	v := &jwtVerifier{
+		issuer:   os.Getenv("JWT_ISSUER"),
+		audience: os.Getenv("JWT_AUDIENCE"),
+		parser:   jwt.NewParser(jwt.WithValidMethods([]string{"EdDSA", "RS256", "ES256"})),
+	}
+	if v.issuer == "" {
+		v.issuer = "koala-user"
+	}
+	if source := os.Getenv("JWKS_URL"); source != "" {
+		ttl, err := time.ParseDuration(os.Getenv("JWKS_CACHE_TTL"))
+		if err != nil {
+			ttl = 10 * time.Minute
+		}
+		v.jwks = newJwksKeySet(source, ttl)
+		return v
+	}
+	pem, err := os.ReadFile(os.Getenv("JWT_PUBLIC_KEY_FILE"))
+	if err != nil {
+		log.SetPrefix("[Warning] ")
+		log.Println("token verification has no keys:", err)
+		return v
+	}
+	if v.static, err = parsePublicKey(pem); err != nil {
+		log.SetPrefix("[Warning] ")
+		log.Println("token verification has no keys:", err)
+	}
+	return v
+}
+
+func (v *jwtVerifier) Verify(ctx context.Context, token string) (entity.ClaimsEntity, error) {
+	claims := &accessClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if v.jwks != nil {
+			kid, _ := t.Header["kid"].(string)
+			return v.jwks.key(ctx, kid)
+		}
+		if v.static == nil {
+			return nil, errors.New("no verification key configured")
+		}
+		return v.static, nil
+	})
+	if err != nil {
+		return entity.ClaimsEntity{}, err
+	}
+	if !claims.VerifyIssuer(v.issuer, true) {
+		return entity.ClaimsEntity{}, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if v.audience != "" && !claims.VerifyAudience(v.audience, true) {
+		return entity.ClaimsEntity{}, errors.New("token not intended for this audience")
+	}
+	if claims.Subject == "" {
+		return entity.ClaimsEntity{}, errors.New("token has no subject")
+	}
+	result := entity.ClaimsEntity{
+		Subject:   claims.Subject,
+		SessionID: claims.Sid,
+		Scopes:    append(strings.Fields(claims.Scope), claims.Scp...),
+	}
+	if claims.ExpiresAt != nil {
+		result.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return result, nil
+}
+
+func parsePublicKey(pem []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported public key")
+}
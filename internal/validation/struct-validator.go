@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"errors"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/koalachatapp/user/internal/core/errs"
+	"github.com/koalachatapp/user/internal/core/port"
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+type structValidator struct {
+	validate *validator.Validate
+}
+
+// NewValidator checks the validate tags of the request entities. Besides
+// the built-in rules it knows:
+//
+//	username  letters, digits, '.', '_' and '-', starting with a letter or digit
+//	email     an RFC 5322 address whose domain may be internationalized
+//	name      NFC normalized text without control characters
+//
+// Fields are reported under their json names.
+func NewValidator() port.Validator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("email", func(fl validator.FieldLevel) bool {
+		return isEmail(fl.Field().String())
+	})
+	v.RegisterValidation("name", func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		if !norm.NFC.IsNormalString(s) {
+			return false
+		}
+		for _, r := range s {
+			if unicode.IsControl(r) {
+				return false
+			}
+		}
+		return true
+	})
+	return &structValidator{validate: v}
+}
+
+func (s *structValidator) Validate(v interface{}, fields ...string) error {
+	var err error
+	if len(fields) > 0 {
+		err = s.validate.StructPartial(v, fields...)
+	} else {
+		err = s.validate.Struct(v)
+	}
+	var failed validator.ValidationErrors
+	if !errors.As(err, &failed) {
+		return err
+	}
+	messages := make([]string, 0, len(failed))
+	report := make([]errs.FieldError, 0, len(failed))
+	for _, f := range failed {
+		message := describe(f)
+		messages = append(messages, message)
+		report = append(report, errs.FieldError{Field: f.Field(), Rule: f.Tag(), Message: message})
+	}
+	return errs.Validation(strings.Join(messages, ";"), report...)
+}
+
+func describe(f validator.FieldError) string {
+	switch f.Tag() {
+	case "required":
+		return f.Field() + " cannot be empty"
+	case "min":
+		return f.Field() + " must be at least " + f.Param() + " characters"
+	case "max":
+		return f.Field() + " must be at most " + f.Param() + " characters"
+	case "username":
+		return f.Field() + " may only contain letters, digits, '.', '_' and '-' and must start with a letter or digit"
+	case "email":
+		return "invalid email address"
+	case "url":
+		return f.Field() + " must be a URL"
+	case "name":
+		return f.Field() + " must not contain control characters"
+	}
+	return f.Field() + " is invalid"
+}
+
+// isEmail reports whether s is a bare RFC 5322 address with a local part
+// of at most 64 bytes and a domain that converts to a valid IDNA name.
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return false
+	}
+	at := strings.LastIndexByte(s, '@')
+	if at < 1 || at > 64 {
+		return false
+	}
+	domain, err := idna.Lookup.ToASCII(s[at+1:])
+	if err != nil || len(domain) > 253 || !strings.Contains(domain, ".") {
+		return false
+	}
+	return true
+}
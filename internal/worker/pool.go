@@ -0,0 +1,267 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/google/uuid"
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+var (
+	ErrStopped   = errors.New("worker pool stopped")
+	ErrQueueFull = errors.New("worker queue full")
+)
+
+// runningKey marks the context of a running job with its pool.
+type runningKey struct{}
+
+type task struct {
+	job    port.Job
+	record entity.JobEntity
+}
+
+type pool struct {
+	size  int
+	queue chan task
+	wg    sync.WaitGroup
+
+	mu       sync.RWMutex
+	handlers map[string]port.JobHandler
+	results  []func(entity.JobEntity, error)
+	done     <-chan struct{}
+	cancel   context.CancelFunc
+	stopped  bool
+	running  map[string]entity.JobEntity
+}
+
+// NewPool creates a pool of WORKER_POOL_SIZE goroutines (default 10) fed by
+// a queue of WORKER_QUEUE_SIZE jobs (default 256).
+func NewPool() port.Worker {
+	return &pool{
+		size:     envInt("WORKER_POOL_SIZE", 10),
+		queue:    make(chan task, envInt("WORKER_QUEUE_SIZE", 256)),
+		handlers: map[string]port.JobHandler{},
+		running:  map[string]entity.JobEntity{},
+	}
+}
+
+func (p *pool) Handle(handler port.JobHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[handler.Kind()] = handler
+}
+
+func (p *pool) OnResult(fn func(entity.JobEntity, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.results = append(p.results, fn)
+}
+
+func (p *pool) Submit(ctx context.Context, job port.Job) error {
+	payload, err := sonic.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return p.enqueue(ctx, task{
+		job: job,
+		record: entity.JobEntity{
+			ID:        uuid.New().String(),
+			Kind:      job.Kind(),
+			Payload:   payload,
+			CreatedAt: time.Now(),
+		},
+	})
+}
+
+func (p *pool) Resubmit(ctx context.Context, record entity.JobEntity) error {
+	handler, err := p.handler(record.Kind)
+	if err != nil {
+		return err
+	}
+	job, err := handler.Decode(record.Payload)
+	if err != nil {
+		return err
+	}
+	return p.enqueue(ctx, task{job: job, record: record})
+}
+
+func (p *pool) enqueue(ctx context.Context, t task) error {
+	// a follow-up of a running job: the job holds a count of wg, so adding
+	// to it is safe even while Shutdown waits
+	followup := ctx.Value(runningKey{}) == p
+	p.mu.RLock()
+	done, stopped := p.done, p.stopped
+	if !stopped || followup {
+		// under the lock, so once Shutdown set stopped nobody else adds
+		// and its Wait never races an Add from zero
+		p.wg.Add(1)
+	}
+	p.mu.RUnlock()
+	if stopped && !followup {
+		return ErrStopped
+	}
+	if followup {
+		// every worker may be waiting to queue one, and after shutdown
+		// none is left, so these go to the retry store instead
+		select {
+		case <-done:
+			p.wg.Done()
+			return p.overflow(t.record)
+		default:
+		}
+		select {
+		case p.queue <- t:
+			return nil
+		default:
+			p.wg.Done()
+			return p.overflow(t.record)
+		}
+	}
+	select {
+	case p.queue <- t:
+		return nil
+	case <-ctx.Done():
+		p.wg.Done()
+		return ctx.Err()
+	case <-done:
+		p.wg.Done()
+		return ErrStopped
+	}
+}
+
+// overflow reports record as failed with ErrQueueFull, so the result
+// callbacks persist it for a retry.
+func (p *pool) overflow(record entity.JobEntity) error {
+	p.mu.RLock()
+	results := p.results
+	p.mu.RUnlock()
+	if len(results) == 0 {
+		return ErrQueueFull
+	}
+	for _, fn := range results {
+		fn(record, ErrQueueFull)
+	}
+	return nil
+}
+
+func (p *pool) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	p.mu.Lock()
+	p.done = ctx.Done()
+	p.cancel = cancel
+	p.mu.Unlock()
+	for i := 0; i < p.size; i++ {
+		go p.run(ctx)
+	}
+}
+
+func (p *pool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *pool) Shutdown(ctx context.Context) []entity.JobEntity {
+	// refuse new jobs before waiting; queued and running ones still drain
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+	var timeout bool
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		timeout = true
+	}
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mu.Unlock()
+	if !timeout {
+		return nil
+	}
+
+	p.mu.RLock()
+	left := make([]entity.JobEntity, 0, len(p.running)+len(p.queue))
+	for _, record := range p.running {
+		left = append(left, record)
+	}
+	p.mu.RUnlock()
+	for {
+		select {
+		case t := <-p.queue:
+			left = append(left, t.record)
+			p.wg.Done()
+		default:
+			return left
+		}
+	}
+}
+
+func (p *pool) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-p.queue:
+			p.mu.Lock()
+			p.running[t.record.ID] = t.record
+			p.mu.Unlock()
+			err := p.exec(ctx, t)
+			if err != nil {
+				log.Println(err)
+			}
+			p.mu.Lock()
+			delete(p.running, t.record.ID)
+			results := p.results
+			p.mu.Unlock()
+			for _, fn := range results {
+				fn(t.record, err)
+			}
+			p.wg.Done()
+		}
+	}
+}
+
+func (p *pool) exec(ctx context.Context, t task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %s (%s) panicked: %v", t.record.ID, t.record.Kind, r)
+		}
+	}()
+	handler, err := p.handler(t.record.Kind)
+	if err != nil {
+		return err
+	}
+	return handler.Run(context.WithValue(ctx, runningKey{}, p), t.job)
+}
+
+func (p *pool) handler(kind string) (port.JobHandler, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	handler, ok := p.handlers[kind]
+	if !ok {
+		return nil, fmt.Errorf("no handler for job kind %q", kind)
+	}
+	return handler, nil
+}
+
+func envInt(key string, def int) int {
+	i, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || i <= 0 {
+		return def
+	}
+	return i
+}
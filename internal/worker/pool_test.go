@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/koalachatapp/user/internal/core/entity"
+	"github.com/koalachatapp/user/internal/core/port"
+)
+
+type parentJob struct {
+	Followups int `json:"followups"`
+}
+
+func (parentJob) Kind() string { return "parent" }
+
+type childJob struct{}
+
+func (childJob) Kind() string { return "child" }
+
+func TestFollowupsDoNotBlock(t *testing.T) {
+	p := &pool{
+		size:     1,
+		queue:    make(chan task, 1),
+		handlers: map[string]port.JobHandler{},
+		running:  map[string]entity.JobEntity{},
+	}
+	var mu sync.Mutex
+	var overflowed, ran int
+	p.OnResult(func(record entity.JobEntity, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if errors.Is(err, ErrQueueFull) {
+			overflowed++
+		}
+	})
+	p.Handle(port.HandlerFunc(func(ctx context.Context, job parentJob) error {
+		for i := 0; i < job.Followups; i++ {
+			if err := p.Submit(ctx, childJob{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	p.Handle(port.HandlerFunc(func(ctx context.Context, job childJob) error {
+		mu.Lock()
+		ran++
+		mu.Unlock()
+		return nil
+	}))
+	p.Start(context.Background())
+
+	// the only worker submits more follow-ups than the queue holds
+	if err := p.Submit(context.Background(), parentJob{Followups: 3}); err != nil {
+		t.Fatal(err)
+	}
+	waited := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool deadlocked on follow-up jobs")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if ran+overflowed != 3 || overflowed == 0 {
+		t.Errorf("ran %d and overflowed %d follow-ups, want 3 with some overflowing", ran, overflowed)
+	}
+}
+
+func TestSubmitBlocksCallers(t *testing.T) {
+	p := &pool{
+		size:     1,
+		queue:    make(chan task, 1),
+		handlers: map[string]port.JobHandler{},
+		running:  map[string]entity.JobEntity{},
+	}
+	// not started, so the queue fills up and a caller waits for its ctx
+	if err := p.Submit(context.Background(), childJob{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Submit(ctx, childJob{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestShutdownRefusesSubmits(t *testing.T) {
+	p := &pool{
+		size:     1,
+		queue:    make(chan task, 4),
+		handlers: map[string]port.JobHandler{},
+		running:  map[string]entity.JobEntity{},
+	}
+	release := make(chan struct{})
+	followup := make(chan error, 1)
+	started := make(chan struct{})
+	var ran sync.WaitGroup
+	ran.Add(1)
+	p.Handle(port.HandlerFunc(func(ctx context.Context, job parentJob) error {
+		close(started)
+		<-release
+		followup <- p.Submit(ctx, childJob{})
+		return nil
+	}))
+	p.Handle(port.HandlerFunc(func(ctx context.Context, job childJob) error {
+		ran.Done()
+		return nil
+	}))
+	p.Start(context.Background())
+	if err := p.Submit(context.Background(), parentJob{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	drained := make(chan []entity.JobEntity)
+	go func() {
+		drained <- p.Shutdown(context.Background())
+	}()
+	// Shutdown refuses others before it waits for the running job
+	for {
+		p.mu.RLock()
+		stopped := p.stopped
+		p.mu.RUnlock()
+		if stopped {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := p.Submit(context.Background(), childJob{}); !errors.Is(err, ErrStopped) {
+		t.Errorf("err = %v, want %v", err, ErrStopped)
+	}
+	close(release)
+	if err := <-followup; err != nil {
+		t.Errorf("follow-up refused while draining: %v", err)
+	}
+	ran.Wait()
+	select {
+	case left := <-drained:
+		if len(left) != 0 {
+			t.Errorf("%d jobs left, want none", len(left))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not drain")
+	}
+}